@@ -11,6 +11,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/havulv/reflector/cmd/k8s"
@@ -31,13 +33,27 @@ type ReflectorArgs struct {
 	Verbose       *bool
 	CmdVersion    *bool
 	Metrics       *bool
-	Namespace     *string
+	Namespace     *[]string
+	Kinds         *[]string
+	Resources     *[]string
 	MetricsAddr   *string
 	WorkerCon     *int
 	ReflectCon    *int
 	Retries       *int
 	CascadeDelete *bool
 	KubeConfig    *string
+	ExcludeNs     *[]string
+	AllowTypes    *[]string
+	DenyTypes     *[]string
+	AllowLabels   *[]string
+	DenyLabels    *[]string
+	AllowAnn      *[]string
+	DenyAnn       *[]string
+	Prune         *bool
+	Unreflect     *string
+	Consent       *bool
+	Recreate      *bool
+	Overrides     *bool
 }
 
 func startReflector(
@@ -49,11 +65,10 @@ func startReflector(
 	newReflector func(
 		zerolog.Logger,
 		kubernetes.Interface,
-		int, int, int,
-		bool,
-		string,
+		reflect.Options,
 	) (reflect.Reflector, error),
 	clientClosure func(*string) (kubernetes.Interface, error),
+	dynamicClosure func(*string) (dynamic.Interface, error),
 	rArgs *ReflectorArgs,
 ) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
@@ -63,8 +78,71 @@ func startReflector(
 		}
 		logger = setLogLevel(logger, *rArgs.Verbose)
 
-		if *rArgs.Namespace == "" {
-			*rArgs.Namespace = os.Getenv("POD_NAMESPACE")
+		if len(*rArgs.Namespace) == 0 {
+			if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+				*rArgs.Namespace = []string{ns}
+			}
+		}
+
+		kinds := []reflect.Kind{}
+		if rArgs.Kinds != nil {
+			for _, k := range *rArgs.Kinds {
+				kinds = append(kinds, reflect.Kind(k))
+			}
+		}
+
+		resources := []schema.GroupVersionResource{}
+		if rArgs.Resources != nil {
+			for _, r := range *rArgs.Resources {
+				resource, err := reflect.ParseResource(r)
+				if err != nil {
+					return errors.Wrap(err, "unable to parse resources")
+				}
+				resources = append(resources, resource)
+			}
+		}
+
+		// unset options fall back to the reflector's defaults
+		var allowTypes, denyTypes []string
+		if rArgs.AllowTypes != nil {
+			allowTypes = *rArgs.AllowTypes
+		}
+		if rArgs.DenyTypes != nil {
+			denyTypes = *rArgs.DenyTypes
+		}
+		var allowLabels, denyLabels, allowAnn, denyAnn []string
+		if rArgs.AllowLabels != nil {
+			allowLabels = *rArgs.AllowLabels
+		}
+		if rArgs.DenyLabels != nil {
+			denyLabels = *rArgs.DenyLabels
+		}
+		if rArgs.AllowAnn != nil {
+			allowAnn = *rArgs.AllowAnn
+		}
+		if rArgs.DenyAnn != nil {
+			denyAnn = *rArgs.DenyAnn
+		}
+		var excludeNs []string
+		if rArgs.ExcludeNs != nil {
+			excludeNs = *rArgs.ExcludeNs
+		}
+		var unreflectPolicy reflect.UnreflectPolicy
+		if rArgs.Unreflect != nil {
+			unreflectPolicy = reflect.UnreflectPolicy(*rArgs.Unreflect)
+		}
+		var prune, consent, recreate, overrides bool
+		if rArgs.Prune != nil {
+			prune = *rArgs.Prune
+		}
+		if rArgs.Consent != nil {
+			consent = *rArgs.Consent
+		}
+		if rArgs.Recreate != nil {
+			recreate = *rArgs.Recreate
+		}
+		if rArgs.Overrides != nil {
+			overrides = *rArgs.Overrides
 		}
 
 		client, err := clientClosure(rArgs.KubeConfig)
@@ -72,6 +150,15 @@ func startReflector(
 			return errors.Wrap(err, "unable to create k8s client")
 		}
 
+		// the dynamic client is only needed for reflecting resources
+		var dynamicClient dynamic.Interface
+		if len(resources) > 0 {
+			dynamicClient, err = dynamicClosure(rArgs.KubeConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create dynamic k8s client")
+			}
+		}
+
 		// Ensure that, if either component goes through
 		// a catastrophic error, then the context will
 		// be cancelled and all components will begin shutdown
@@ -104,11 +191,28 @@ func startReflector(
 		reflector, err := newReflector(
 			logger.With().Str("component", "reflector").Logger(),
 			client,
-			*rArgs.ReflectCon,
-			*rArgs.WorkerCon,
-			*rArgs.Retries,
-			*rArgs.CascadeDelete,
-			*rArgs.Namespace)
+			reflect.Options{
+				Namespaces:         *rArgs.Namespace,
+				Kinds:              kinds,
+				Resources:          resources,
+				Dynamic:            dynamicClient,
+				ReflectConcurrency: *rArgs.ReflectCon,
+				WorkerConcurrency:  *rArgs.WorkerCon,
+				Retries:            *rArgs.Retries,
+				CascadeDelete:      *rArgs.CascadeDelete,
+				ExcludeNamespaces:  excludeNs,
+				Prune:              prune,
+				UnreflectPolicy:    unreflectPolicy,
+				RequireConsent:     consent,
+				RecreateImmutable:  recreate,
+				Overrides:          overrides,
+				AllowedSecretTypes: allowTypes,
+				DeniedSecretTypes:  denyTypes,
+				AllowedLabels:      allowLabels,
+				DeniedLabels:       denyLabels,
+				AllowedAnnotations: allowAnn,
+				DeniedAnnotations:  denyAnn,
+			})
 		if err != nil {
 			return errors.Wrap(err, "unable to start reflector")
 		}
@@ -144,15 +248,29 @@ to others.  `, " "),
 			server.NewMetricsServer,
 			reflect.NewReflector,
 			k8s.CreateK8sClient,
+			k8s.CreateDynamicClient,
 			&args),
 	}
 
 	args.KubeConfig = cmd.Flags().String(
 		"kube-config", "",
 		"The path to a kubernetes configuration if running outside a cluster")
-	args.Namespace = cmd.Flags().StringP(
-		"namespace", "n", "",
-		`The namespace to sync secrets from`)
+	args.Namespace = cmd.Flags().StringSliceP(
+		"namespace", "n", []string{},
+		`The namespaces to sync secrets from. Can be
+given more than once or as a comma separated list.
+Defaults to the namespace in POD_NAMESPACE.`)
+	args.Kinds = cmd.Flags().StringSlice(
+		"kinds", []string{string(reflect.KindSecrets)},
+		`The kinds of objects to reflect. Any of
+secrets and configmaps. Only secrets are
+reflected by default.`)
+	args.Resources = cmd.Flags().StringSlice(
+		"resources", []string{},
+		`Other namespaced resources to reflect, as
+group/version/resource, or version/resource for
+the core group, e.g.
+networking.k8s.io/v1/networkpolicies`)
 	args.Retries = cmd.Flags().IntP(
 		"retries", "r", defaultRetries,
 		`The number of times to retry reflecting a
@@ -182,6 +300,75 @@ This can be very dangerous to set, and is
 not recommended unless you are
 _absolutely certain_ it fits your use case
 ***WARNING***`)
+	args.Prune = cmd.Flags().Bool(
+		"prune", false,
+		`If enabled, reflected secrets are deleted from
+namespaces that the original secret no longer
+targets. Secrets can override this with the
+reflector.havulv.io/prune annotation.`)
+	args.Unreflect = cmd.Flags().String(
+		"unreflect-policy", string(reflect.UnreflectFreeze),
+		`What happens to reflected secrets when the
+original secret stops being reflected. One of
+freeze (leave them as they are), orphan (release
+them from the reflector's ownership) or delete.`)
+	args.Consent = cmd.Flags().Bool(
+		"require-consent", false,
+		`If enabled, secrets are only reflected into
+namespaces which accept them with the
+reflector.havulv.io/accept-from annotation.
+Otherwise namespaces without the annotation
+accept every secret.`)
+	args.Recreate = cmd.Flags().Bool(
+		"recreate-immutable", false,
+		`If enabled, immutable reflected secrets are
+deleted and created again when the original
+secret changes. Otherwise they are left as
+they are.`)
+	args.Overrides = cmd.Flags().Bool(
+		"overrides", false,
+		`If enabled, secrets and config maps in every
+namespace are watched for the
+reflector.havulv.io/override-of annotation,
+which overrides the data of the reflections
+in their namespace.`)
+	args.ExcludeNs = cmd.Flags().StringSlice(
+		"exclude-namespaces", []string{},
+		`Namespaces which secrets are never reflected
+to, regardless of their annotations. Accepts
+namespace names, globs (e.g. kube-*) and
+regexes prefixed with regex:`)
+	args.AllowTypes = cmd.Flags().StringSlice(
+		"allow-secret-types", []string{},
+		`The only types of secrets which are reflected.
+Every type is allowed if none are given.`)
+	args.DenyTypes = cmd.Flags().StringSlice(
+		"deny-secret-types", append([]string{}, reflect.DefaultDeniedSecretTypes...),
+		`Types of secrets which are never reflected,
+even if they are allowed. Service account and
+bootstrap tokens are denied by default.`)
+	args.AllowLabels = cmd.Flags().StringSlice(
+		"allow-labels", []string{},
+		`The only labels which are carried over to
+reflected secrets, as keys or globs. Every
+label is allowed if none are given.`)
+	args.DenyLabels = cmd.Flags().StringSlice(
+		"deny-labels", append([]string{}, reflect.DefaultDeniedLabels...),
+		`Labels which are never carried over to
+reflected secrets, as keys or globs, even if
+they are allowed. GitOps tracking labels are
+denied by default.`)
+	args.AllowAnn = cmd.Flags().StringSlice(
+		"allow-annotations", []string{},
+		`The only annotations which are carried over
+to reflected secrets, as keys or globs. Every
+annotation is allowed if none are given.`)
+	args.DenyAnn = cmd.Flags().StringSlice(
+		"deny-annotations", append([]string{}, reflect.DefaultDeniedAnnotations...),
+		`Annotations which are never carried over to
+reflected secrets, as keys or globs, even if
+they are allowed. kubectl, Helm and GitOps
+annotations are denied by default.`)
 	args.CmdVersion = cmd.Flags().Bool(
 		"version", false, "Output version information")
 	args.Verbose = cmd.Flags().BoolP("verbose", "v", false, "Enable verbose logging")
@@ -12,6 +12,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 
@@ -26,12 +30,12 @@ const defaultAddr = "localhost:8080"
 
 func createMocks(
 	metricsArgsAssert func(string),
-	reflectArgsAssert func(int, int, int, bool, string),
+	reflectArgsAssert func(reflect.Options),
 ) (
 	*mocks.MetricsServer,
 	*mocks.Reflector,
 	func(zerolog.Logger, string) server.MetricsServer,
-	func(zerolog.Logger, kubernetes.Interface, int, int, int, bool, string) (reflect.Reflector, error),
+	func(zerolog.Logger, kubernetes.Interface, reflect.Options) (reflect.Reflector, error),
 ) {
 	mockServer := &mocks.MetricsServer{}
 	metricsServer := func(l zerolog.Logger, a string) server.MetricsServer {
@@ -39,25 +43,29 @@ func createMocks(
 		return mockServer
 	}
 	reflector := &mocks.Reflector{}
-	newReflector := func(l zerolog.Logger, k kubernetes.Interface, a int, b int, c int, d bool, e string) (reflect.Reflector, error) {
-		reflectArgsAssert(a, b, c, d, e)
+	newReflector := func(l zerolog.Logger, k kubernetes.Interface, o reflect.Options) (reflect.Reflector, error) {
+		reflectArgsAssert(o)
 		return reflector, nil
 	}
 	return mockServer, reflector, metricsServer, newReflector
 }
 
+func noDynamicClient(s *string) (dynamic.Interface, error) {
+	return nil, errors.New("no dynamic client")
+}
+
 func TestStartReflector(t *testing.T) {
 	t.Run("tests that version dumps the version", func(t *testing.T) {
 		buf := bytes.NewBuffer([]byte{})
 		logger := zerolog.New(buf)
 		t.Parallel()
 		_, _, metricsServer, newReflector := createMocks(
-			func(s string) {}, func(a int, b int, c int, d bool, e string) {})
+			func(s string) {}, func(o reflect.Options) {})
 
 		cmdVersion := true
 		version.CommitHash = "thing"
 		version.OutputFunc = func(f string, a ...interface{}) (int, error) {
-			return fmt.Fprintf(buf, f, a)
+			return fmt.Fprintf(buf, f, a...)
 		}
 		defer func() {
 			version.CommitHash = ""
@@ -68,6 +76,7 @@ func TestStartReflector(t *testing.T) {
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				CmdVersion: &cmdVersion,
 			})
@@ -79,13 +88,441 @@ func TestStartReflector(t *testing.T) {
 		logger := zerolog.New(buf)
 		t.Parallel()
 		verbose := false
-		namespace := defaultNamespace
+		namespace := []string{defaultNamespace}
 		conn := 0
 		require.Nil(t, os.Setenv("POD_NAMESPACE", "kube-system"))
 
 		_, r, metricsServer, newReflector := createMocks(
-			func(s string) {}, func(a int, b int, c int, d bool, n string) {
-				assert.Equal(t, n, namespace)
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, o.Namespaces, namespace)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that excluded namespaces are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		conn := 0
+		exclude := []string{"kube-*", "default"}
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, exclude, o.ExcludeNamespaces)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				ExcludeNs:     &exclude,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that every namespace is passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{"hub-a", "hub-b", "hub-c"}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, namespace, o.Namespaces)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that kinds are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		kinds := []string{"secrets", "configmaps"}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, []reflect.Kind{reflect.KindSecrets, reflect.KindConfigMaps}, o.Kinds)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				Kinds:         &kinds,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that resources are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		resources := []string{"networking.k8s.io/v1/networkpolicies", "v1/services"}
+		conn := 0
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, []schema.GroupVersionResource{
+					{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+					{Version: "v1", Resource: "services"},
+				}, o.Resources)
+				assert.Equal(t, dynamicClient, o.Dynamic)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			func(s *string) (dynamic.Interface, error) { return dynamicClient, nil },
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				Resources:     &resources,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that invalid resources are caught", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		resources := []string{"networkpolicies"}
+
+		_, _, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {})
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:   &verbose,
+				Namespace: &namespace,
+				Resources: &resources,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.NotNil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that failures to create the dynamic client are caught", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		resources := []string{"v1/services"}
+
+		_, _, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {})
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:   &verbose,
+				Namespace: &namespace,
+				Resources: &resources,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.NotNil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that secret types are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		allow := []string{"kubernetes.io/tls"}
+		deny := []string{}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, allow, o.AllowedSecretTypes)
+				assert.Equal(t, deny, o.DeniedSecretTypes)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				AllowTypes:    &allow,
+				DenyTypes:     &deny,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that metadata policies are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		allowLabels := []string{"team"}
+		denyLabels := []string{}
+		allowAnn := []string{"example.com/*"}
+		denyAnn := []string{"example.com/secret"}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Equal(t, allowLabels, o.AllowedLabels)
+				assert.Equal(t, denyLabels, o.DeniedLabels)
+				assert.Equal(t, allowAnn, o.AllowedAnnotations)
+				assert.Equal(t, denyAnn, o.DeniedAnnotations)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				AllowLabels:   &allowLabels,
+				DenyLabels:    &denyLabels,
+				AllowAnn:      &allowAnn,
+				DenyAnn:       &denyAnn,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that recreating immutable reflections is passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		recreate := true
+		namespace := []string{defaultNamespace}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.True(t, o.RecreateImmutable)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				Recreate:      &recreate,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that watching overrides is passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		overrides := true
+		namespace := []string{defaultNamespace}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.True(t, o.Overrides)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				Overrides:     &overrides,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that pruning, the unreflect policy and consent are passed to the reflector", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		prune := true
+		policy := string(reflect.UnreflectDelete)
+		consent := true
+		namespace := []string{defaultNamespace}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.True(t, o.Prune)
+				assert.Equal(t, reflect.UnreflectDelete, o.UnreflectPolicy)
+				assert.True(t, o.RequireConsent)
+			})
+		r.On("Start", mock.Anything).Return(nil)
+
+		startFunc := startReflector(
+			logger,
+			metricsServer,
+			newReflector,
+			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
+			&ReflectorArgs{
+				Verbose:       &verbose,
+				Namespace:     &namespace,
+				ReflectCon:    &conn,
+				WorkerCon:     &conn,
+				Retries:       &conn,
+				CascadeDelete: &verbose,
+				Prune:         &prune,
+				Unreflect:     &policy,
+				Consent:       &consent,
+			})
+		cmd := &cobra.Command{}
+		assert.Nil(t, cmd.Execute())
+		assert.Nil(t, startFunc(cmd, []string{}))
+	})
+
+	t.Run("tests that unset options fall back to the reflector's defaults", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{})
+		logger := zerolog.New(buf)
+		t.Parallel()
+		verbose := false
+		namespace := []string{defaultNamespace}
+		conn := 0
+
+		_, r, metricsServer, newReflector := createMocks(
+			func(s string) {}, func(o reflect.Options) {
+				assert.Nil(t, o.ExcludeNamespaces)
+				assert.False(t, o.Prune)
+				assert.Equal(t, reflect.UnreflectPolicy(""), o.UnreflectPolicy)
+				assert.False(t, o.RequireConsent)
+				assert.False(t, o.RecreateImmutable)
+				assert.False(t, o.Overrides)
 			})
 		r.On("Start", mock.Anything).Return(nil)
 
@@ -94,6 +531,7 @@ func TestStartReflector(t *testing.T) {
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				Verbose:       &verbose,
 				Namespace:     &namespace,
@@ -112,16 +550,17 @@ func TestStartReflector(t *testing.T) {
 		logger := zerolog.New(buf)
 		t.Parallel()
 		verbose := false
-		namespace := defaultNamespace
+		namespace := []string{defaultNamespace}
 
 		_, _, metricsServer, newReflector := createMocks(
-			func(s string) {}, func(a int, b int, c int, d bool, n string) {})
+			func(s string) {}, func(o reflect.Options) {})
 
 		startFunc := startReflector(
 			logger,
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return nil, errors.New("err") },
+			noDynamicClient,
 			&ReflectorArgs{
 				Verbose:   &verbose,
 				Namespace: &namespace,
@@ -135,7 +574,7 @@ func TestStartReflector(t *testing.T) {
 		buf := bytes.NewBuffer([]byte{})
 		logger := zerolog.New(buf)
 		t.Parallel()
-		ns := defaultNamespace
+		ns := []string{defaultNamespace}
 		addr := defaultAddr
 		metrics := true
 		verbose := false
@@ -144,7 +583,7 @@ func TestStartReflector(t *testing.T) {
 		m, r, metricsServer, newReflector := createMocks(
 			func(s string) {
 				assert.Equal(t, s, addr)
-			}, func(a int, b int, c int, d bool, n string) {})
+			}, func(o reflect.Options) {})
 		m.On("Run", mock.Anything).Return(nil)
 		r.On("Start", mock.Anything).Return(nil)
 
@@ -153,6 +592,7 @@ func TestStartReflector(t *testing.T) {
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				Namespace:     &ns,
 				Metrics:       &metrics,
@@ -170,7 +610,7 @@ func TestStartReflector(t *testing.T) {
 
 	t.Run("tests that metrics errors are logged", func(t *testing.T) {
 		t.Parallel()
-		ns := defaultNamespace
+		ns := []string{defaultNamespace}
 		addr := defaultAddr
 		metrics := true
 		verbose := false
@@ -179,7 +619,7 @@ func TestStartReflector(t *testing.T) {
 		m, r, metricsServer, newReflector := createMocks(
 			func(s string) {
 				assert.Equal(t, s, addr)
-			}, func(a int, b int, c int, d bool, n string) {})
+			}, func(o reflect.Options) {})
 		m.On("Run", mock.Anything).Return(errors.New("some error"))
 		r.On("Start", mock.Anything).Return(nil)
 
@@ -190,6 +630,7 @@ func TestStartReflector(t *testing.T) {
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				Namespace:     &ns,
 				Metrics:       &metrics,
@@ -211,7 +652,7 @@ func TestStartReflector(t *testing.T) {
 
 	t.Run("tests that reflector errors are caught", func(t *testing.T) {
 		t.Parallel()
-		ns := ""
+		ns := []string{}
 		addr := defaultAddr
 		metrics := true
 		verbose := false
@@ -220,7 +661,7 @@ func TestStartReflector(t *testing.T) {
 		m, r, metricsServer, newReflector := createMocks(
 			func(s string) {
 				assert.Equal(t, s, addr)
-			}, func(a int, b int, c int, d bool, n string) {})
+			}, func(o reflect.Options) {})
 		m.On("Run", mock.Anything).Return(nil)
 		r.On("Start", mock.Anything).Return(errors.New("some error"))
 
@@ -231,6 +672,7 @@ func TestStartReflector(t *testing.T) {
 			metricsServer,
 			newReflector,
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				Namespace:     &ns,
 				Metrics:       &metrics,
@@ -254,24 +696,25 @@ func TestStartReflector(t *testing.T) {
 		t.Parallel()
 		buf := bytes.NewBuffer([]byte{})
 		logger := zerolog.New(buf)
-		ns := defaultNamespace
+		ns := []string{defaultNamespace}
 		addr := defaultAddr
 		metrics := true
 		verbose := false
 		conn := 0
 
 		m, r, metricsServer, _ := createMocks(
-			func(s string) {}, func(a int, b int, c int, d bool, n string) {})
+			func(s string) {}, func(o reflect.Options) {})
 		m.On("Run", mock.Anything).Return(nil)
 		r.On("Start", mock.Anything).Return(errors.New("some error"))
 
 		startFunc := startReflector(
 			logger,
 			metricsServer,
-			func(l zerolog.Logger, k kubernetes.Interface, a int, b int, c int, d bool, e string) (reflect.Reflector, error) {
+			func(l zerolog.Logger, k kubernetes.Interface, o reflect.Options) (reflect.Reflector, error) {
 				return r, errors.New("can't start")
 			},
 			func(s *string) (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil },
+			noDynamicClient,
 			&ReflectorArgs{
 				Namespace:     &ns,
 				Metrics:       &metrics,
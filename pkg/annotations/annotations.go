@@ -8,10 +8,17 @@ package annotations
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
@@ -25,9 +32,89 @@ const (
 	// NamespaceAnnotation is the annotation which determines which
 	// namespaces to reflect to
 	NamespaceAnnotation = Prefix + "/namespaces"
+	// NamespaceSelectorAnnotation is a label selector which determines
+	// additional namespaces to reflect to by their labels
+	NamespaceSelectorAnnotation = Prefix + "/namespace-selector"
+	// NamespaceExcludeAnnotation is the annotation which determines which
+	// namespaces are never reflected to, even if they are otherwise selected
+	NamespaceExcludeAnnotation = Prefix + "/namespaces-exclude"
+
+	// KeysAnnotation is the annotation which lists the only data keys
+	// of the secret which are reflected
+	KeysAnnotation = Prefix + "/keys"
+	// KeysExcludeAnnotation is the annotation which lists the data keys
+	// of the secret which are never reflected
+	KeysExcludeAnnotation = Prefix + "/keys-exclude"
+	// KeyMapAnnotation is the annotation which renames data keys of the
+	// secret in its reflections, given as `from=to` entries
+	KeyMapAnnotation = Prefix + "/key-map"
+
+	// TemplateAnnotation is the annotation which holds a JSON object of data keys
+	// and the Go templates rendered from the secret's data into the reflections
+	TemplateAnnotation = Prefix + "/template"
+	// TargetNameAnnotation is the annotation which names the reflections of the
+	// secret, either in every namespace or per namespace as `namespace:name` entries
+	TargetNameAnnotation = Prefix + "/target-name"
+	// TargetTypeAnnotation is the annotation which converts the reflections of the
+	// secret to another type of secret
+	TargetTypeAnnotation = Prefix + "/target-type"
+
+	// AggregateToAnnotation is the annotation which names the aggregate secret
+	// that the data of the secret is merged into, along with the other secrets
+	// which name the same aggregate
+	AggregateToAnnotation = Prefix + "/aggregate-to"
+	// OverrideOfAnnotation is the annotation on a secret in a reflection namespace
+	// which names the secret, given as `namespace/name`, whose reflection in that
+	// namespace the data of the annotated secret is layered on top of
+	OverrideOfAnnotation = Prefix + "/override-of"
+
+	// PruneAnnotation determines if reflections in namespaces that are no
+	// longer targeted by the secret are deleted, overriding the reflector's default
+	PruneAnnotation = Prefix + "/prune"
+
+	// AcceptFromAnnotation is the annotation on a namespace which determines
+	// which secrets, given as `namespace/name`, may be reflected into it
+	AcceptFromAnnotation = Prefix + "/accept-from"
+
+	// PullAnnotation is the annotation on a namespace which lists the
+	// secrets, given as `namespace/name`, to reflect into it
+	PullAnnotation = Prefix + "/pull"
+	// AllowPullAnnotation is the annotation which determines which
+	// namespaces may pull the secret with the pull annotation
+	AllowPullAnnotation = Prefix + "/allow-pull"
+
+	// AttachToServiceAccountsAnnotation is the annotation which lists the service
+	// accounts that the reflected secret is added to as an image pull secret
+	AttachToServiceAccountsAnnotation = Prefix + "/attach-to-serviceaccounts"
+	// AttachedSecretsAnnotation is the annotation on a service account which lists
+	// the image pull secrets that the reflector attached to it, so that only those
+	// are detached when their reflections are deleted
+	AttachedSecretsAnnotation = Prefix + "/attached-secrets"
+	// ProjectToConfigMapAnnotation is the annotation which lists the keys of the
+	// secret that are projected into a config map next to the reflected secret
+	ProjectToConfigMapAnnotation = Prefix + "/project-to-configmap"
+
+	// PriorityAnnotation is the annotation which decides which of two secrets
+	// with the same reflection name is reflected into a namespace. Unlike the
+	// other annotations of the secret, it is carried over to the reflections.
+	PriorityAnnotation = Prefix + "/priority"
 
 	// ReflectedFromAnnotation indicates what the originating namespace of the secret was
 	ReflectedFromAnnotation = Prefix + "/reflected-from"
+	// ReflectedNameAnnotation indicates what the name of the originating secret was
+	ReflectedNameAnnotation = Prefix + "/reflected-name"
+	// ReflectedUIDAnnotation indicates what the UID of the originating secret was
+	ReflectedUIDAnnotation = Prefix + "/reflected-uid"
+	// ProjectedFromAnnotation indicates which secret, given as `namespace/name`, a
+	// config map was projected from. Projections are not reflections of config maps.
+	ProjectedFromAnnotation = Prefix + "/projected-from"
+	// AggregatedFromAnnotation indicates which secrets, given as `namespace/name`,
+	// an aggregate secret was merged from
+	AggregatedFromAnnotation = Prefix + "/aggregated-from"
+	// ReflectionOfLabel labels reflections with a hash of the namespace and name
+	// of the secret they originate from, which are too long for a label value,
+	// so that the reflections of a secret are listed with a label selector
+	ReflectionOfLabel = Prefix + "/reflection-of"
 	// ReflectedAtAnnotation indicates when the annotation was originally reflected
 	ReflectedAtAnnotation = Prefix + "/reflected-at"
 	// ReflectionHashAnnotation is a hash of the reflected secret for quick comparison
@@ -39,41 +126,478 @@ const (
 	ReflectionOwned = "reflector"
 )
 
+// sourceAnnotations are the annotations which configure the reflection
+// of a secret. They are not carried over to the reflected secrets.
+var sourceAnnotations = []string{
+	ReflectAnnotation,
+	NamespaceAnnotation,
+	NamespaceSelectorAnnotation,
+	NamespaceExcludeAnnotation,
+	KeysAnnotation,
+	KeysExcludeAnnotation,
+	KeyMapAnnotation,
+	TemplateAnnotation,
+	TargetNameAnnotation,
+	TargetTypeAnnotation,
+	AggregateToAnnotation,
+	PruneAnnotation,
+	AllowPullAnnotation,
+	AttachToServiceAccountsAnnotation,
+	ProjectToConfigMapAnnotation,
+}
+
 var (
 	// ErrorNoNamespace is used when no namespaces are supplied
 	ErrorNoNamespace = errors.New("no namespace given")
+	// ErrorInvalidKeyMap is used when the key map annotation can't be parsed
+	ErrorInvalidKeyMap = errors.New("invalid key map")
+	// ErrorInvalidTemplate is used when the template annotation can't be parsed
+	ErrorInvalidTemplate = errors.New("invalid template")
+	// ErrorInvalidAggregate is used when the aggregate to annotation is not a valid name
+	ErrorInvalidAggregate = errors.New("invalid aggregate")
+	// ErrorInvalidTargetName is used when the target name annotation can't be parsed
+	ErrorInvalidTargetName = errors.New("invalid target name")
+	// ErrorInvalidTargetType is used when the target type annotation is not a valid secret type
+	ErrorInvalidTargetType = errors.New("invalid target type")
+	// ErrorInvalidPriority is used when the priority annotation is not an integer
+	ErrorInvalidPriority = errors.New("invalid priority")
+	// ErrorInvalidOverride is used when the override of annotation is not a `namespace/name`
+	ErrorInvalidOverride = errors.New("invalid override")
 )
 
+// ShouldReflect checks if a secret is annotated to be reflected
+func ShouldReflect(annotations map[string]string) bool {
+	return annotations[ReflectAnnotation] == "true"
+}
+
+// ShouldPrune checks if a secret's stale reflections should be pruned. The
+// prune annotation takes precedence over the default if it is set.
+func ShouldPrune(annotations map[string]string, def bool) bool {
+	switch annotations[PruneAnnotation] {
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		return def
+	}
+}
+
+// RemoveSourceAnnotations removes the annotations which configure the
+// reflection of a secret
+func RemoveSourceAnnotations(annotations map[string]string) {
+	for _, key := range sourceAnnotations {
+		delete(annotations, key)
+	}
+}
+
+// AcceptsSecret checks if a namespace, given its annotations, consents to
+// the secret being reflected into it. The accept from annotation is a comma
+// separated list of `namespace/name` entries, which may be globs or regexes.
+// Namespaces without the annotation consent unless consent is required.
+func AcceptsSecret(
+	nsAnnotations map[string]string,
+	secretNamespace string,
+	secretName string,
+	requireConsent bool,
+) (bool, error) {
+	acceptFrom, ok := nsAnnotations[AcceptFromAnnotation]
+	if !ok {
+		return !requireConsent, nil
+	}
+
+	accepted, err := compileNamespaces(splitNamespaces(acceptFrom))
+	if err != nil {
+		return false, errors.Wrap(err, "invalid accept from annotation")
+	}
+	return accepted.contains(secretNamespace + "/" + secretName), nil
+}
+
+// ServiceAccounts parses the service accounts which the reflected secret is
+// attached to from the comma separated attach to service accounts annotation
+func ServiceAccounts(annotations map[string]string) []string {
+	return splitEntries(annotations[AttachToServiceAccountsAnnotation])
+}
+
+// AttachedSecrets parses the image pull secrets which the reflector attached
+// to a service account from its comma separated attached secrets annotation
+func AttachedSecrets(annotations map[string]string) []string {
+	return splitEntries(annotations[AttachedSecretsAnnotation])
+}
+
+// ProjectedKeys parses the keys of a secret which are projected into a
+// config map from the comma separated project to config map annotation
+func ProjectedKeys(annotations map[string]string) []string {
+	return splitEntries(annotations[ProjectToConfigMapAnnotation])
+}
+
+// KeepsKey checks if a data key of a secret is reflected, given the secret's
+// annotations. Only the keys listed in the keys annotation are kept if it is
+// set, and keys listed in the keys exclude annotation are never kept.
+func KeepsKey(annotations map[string]string, key string) bool {
+	for _, excluded := range splitEntries(annotations[KeysExcludeAnnotation]) {
+		if excluded == key {
+			return false
+		}
+	}
+
+	included, ok := annotations[KeysAnnotation]
+	if !ok {
+		return true
+	}
+	for _, entry := range splitEntries(included) {
+		if entry == key {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyMap parses the renamed data keys of a secret from the comma separated
+// `from=to` entries of the key map annotation. The new names must be valid
+// data keys, and neither a key nor a new name may be given twice.
+func KeyMap(annotations map[string]string) (map[string]string, error) {
+	keyMap := map[string]string{}
+	mapped := map[string]struct{}{}
+	for _, entry := range splitEntries(annotations[KeyMapAnnotation]) {
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, errors.Wrapf(ErrorInvalidKeyMap, "entry %q is not of the form from=to", entry)
+		}
+		if errs := validation.IsConfigMapKey(to); len(errs) > 0 {
+			return nil, errors.Wrapf(ErrorInvalidKeyMap, "%q is not a valid key: %s", to, strings.Join(errs, ", "))
+		}
+		if _, ok := keyMap[from]; ok {
+			return nil, errors.Wrapf(ErrorInvalidKeyMap, "key %q is mapped more than once", from)
+		}
+		if _, ok := mapped[to]; ok {
+			return nil, errors.Wrapf(ErrorInvalidKeyMap, "more than one key is mapped to %q", to)
+		}
+		keyMap[from] = to
+		mapped[to] = struct{}{}
+	}
+	return keyMap, nil
+}
+
+// splitEntries splits a comma separated list like splitNamespaces,
+// dropping any empty entries
+func splitEntries(str string) []string {
+	entries := []string{}
+	for _, entry := range splitNamespaces(str) {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Templates parses the templates of a secret's reflections from the template
+// annotation, which is a JSON object of data keys and the templates rendered
+// into them. The keys must be valid data keys.
+func Templates(annotations map[string]string) (map[string]string, error) {
+	templates := map[string]string{}
+	raw, ok := annotations[TemplateAnnotation]
+	if !ok || strings.TrimSpace(raw) == "" {
+		return templates, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &templates); err != nil {
+		return nil, errors.Wrapf(ErrorInvalidTemplate, "unable to parse templates: %s", err)
+	}
+	for key := range templates {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, errors.Wrapf(ErrorInvalidTemplate, "%q is not a valid key: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	return templates, nil
+}
+
+// TargetNames are the names of the reflections of a secret by namespace,
+// where the empty namespace names the reflections in every other namespace
+type TargetNames map[string]string
+
+// ParseTargetNames parses the names of the reflections of a secret from the
+// target name annotation. Entries are either a name, which is used in every
+// namespace, or `namespace:name` to name the reflection in a single namespace.
+func ParseTargetNames(annotations map[string]string) (TargetNames, error) {
+	names := TargetNames{}
+	for _, entry := range splitEntries(annotations[TargetNameAnnotation]) {
+		namespace, name, ok := strings.Cut(entry, ":")
+		if !ok {
+			namespace, name = "", entry
+		}
+		namespace, name = strings.TrimSpace(namespace), strings.TrimSpace(name)
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return nil, errors.Wrapf(ErrorInvalidTargetName, "%q is not a valid name: %s", name, strings.Join(errs, ", "))
+		}
+		if _, ok := names[namespace]; ok {
+			return nil, errors.Wrapf(ErrorInvalidTargetName, "namespace %q is named more than once", namespace)
+		}
+		names[namespace] = name
+	}
+	return names, nil
+}
+
+// Name is the name of the reflection in the namespace of the secret with
+// the name, which is kept if the reflection is not renamed
+func (t TargetNames) Name(namespace string, name string) string {
+	if target, ok := t[namespace]; ok {
+		return target
+	}
+	if target, ok := t[""]; ok {
+		return target
+	}
+	return name
+}
+
+// TargetType parses the type which the reflections of the secret are
+// converted to. It is empty if the type is not converted.
+func TargetType(annotations map[string]string) (v1.SecretType, error) {
+	secretType := strings.TrimSpace(annotations[TargetTypeAnnotation])
+	if secretType == "" {
+		return "", nil
+	}
+	if errs := validation.IsQualifiedName(secretType); len(errs) > 0 {
+		return "", errors.Wrapf(ErrorInvalidTargetType, "%q is not a valid type: %s", secretType, strings.Join(errs, ", "))
+	}
+	return v1.SecretType(secretType), nil
+}
+
+// AggregateTo parses the name of the aggregate secret which the secret is
+// merged into. The name is empty if the secret is not aggregated.
+func AggregateTo(annotations map[string]string) (string, error) {
+	name := strings.TrimSpace(annotations[AggregateToAnnotation])
+	if name == "" {
+		return "", nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", errors.Wrapf(ErrorInvalidAggregate, "%q is not a valid name: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// OverrideOf parses the secret, given as `namespace/name`, whose reflections
+// the secret overrides. It is empty if the secret is not an override.
+func OverrideOf(annotations map[string]string) (string, error) {
+	source := strings.TrimSpace(annotations[OverrideOfAnnotation])
+	if source == "" {
+		return "", nil
+	}
+	namespace, name, ok := strings.Cut(source, "/")
+	if !ok || len(validation.IsDNS1123Label(namespace)) > 0 || len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return "", errors.Wrapf(ErrorInvalidOverride, "%q is not of the form namespace/name", source)
+	}
+	return source, nil
+}
+
+// Priority parses the priority of a secret, or of the secret a reflection
+// originates from. Secrets without a priority have a priority of zero.
+func Priority(annotations map[string]string) (int, error) {
+	priority := strings.TrimSpace(annotations[PriorityAnnotation])
+	if priority == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(priority)
+	if err != nil {
+		return 0, errors.Wrapf(ErrorInvalidPriority, "%q is not an integer", priority)
+	}
+	return parsed, nil
+}
+
+// IsReflectionOf checks if a reflected secret, given its annotations and
+// name, originates from the secret with the namespace and name. Reflections
+// without the reflected name annotation are named like their secret.
+//
+// The reflected UID is deliberately not compared. As no two secrets have
+// the same namespace and name at once, it only tells a secret apart from an
+// earlier one of the same name, and comparing it would turn a secret which
+// is deleted and created again into a collision with its own reflections.
+func IsReflectionOf(annotations map[string]string, reflectionName string, namespace string, name string) bool {
+	reflectedName, ok := annotations[ReflectedNameAnnotation]
+	if !ok {
+		reflectedName = reflectionName
+	}
+	return IsReflectedFrom(annotations, namespace) && reflectedName == name
+}
+
+// ReflectionOf is the value of the reflection of label of the reflections of
+// the secret with the namespace and name
+func ReflectionOf(namespace string, name string) string {
+	return fmt.Sprintf("%x", sha256.Sum224([]byte(namespace+"/"+name)))
+}
+
+// ReflectionOfSelector is the label selector of the reflections of the
+// secret with the namespace and name
+func ReflectionOfSelector(namespace string, name string) string {
+	return labels.Set{ReflectionOfLabel: ReflectionOf(namespace, name)}.String()
+}
+
+// IsProjection checks if a reflected config map is projected from a secret,
+// rather than reflected from a config map
+func IsProjection(annotations map[string]string) bool {
+	_, ok := annotations[ProjectedFromAnnotation]
+	return ok
+}
+
+// IsReflectedFrom checks if a reflected secret originates from the namespace
+func IsReflectedFrom(annotations map[string]string, namespace string) bool {
+	return annotations[ReflectedFromAnnotation] == namespace
+}
+
 // CanOperate checks if an operation can be performed on an existing secret
 func CanOperate(annotations map[string]string) bool {
 	return annotations[ReflectionOwnerAnnotation] == ReflectionOwned
 }
 
+// targets are the parsed namespace annotations of a secret which
+// determine the namespaces the secret is reflected to
+type targets struct {
+	all      bool
+	included namespaceList
+	excluded namespaceList
+	selector labels.Selector
+}
+
+// parseTargets parses the namespace annotations of a secret along
+// with the globally excluded namespaces
+func parseTargets(
+	objAnnotations map[string]string,
+	excluded []string,
+) (targets, error) {
+	nsAnnotation := objAnnotations[NamespaceAnnotation]
+	selectorAnnotation := strings.TrimSpace(objAnnotations[NamespaceSelectorAnnotation])
+	if nsAnnotation == "" && selectorAnnotation == "" {
+		return targets{}, ErrorNoNamespace
+	}
+
+	t := targets{}
+	if nsAnnotation != "" {
+		entries, err := parseNamespaces(nsAnnotation)
+		if err != nil {
+			return targets{}, err
+		}
+		t.all = len(entries) == 0
+		t.included, err = compileNamespaces(entries)
+		if err != nil {
+			return targets{}, err
+		}
+	}
+
+	var err error
+	t.excluded, err = compileNamespaces(append(
+		splitNamespaces(objAnnotations[NamespaceExcludeAnnotation]),
+		excluded...))
+	if err != nil {
+		return targets{}, err
+	}
+
+	if selectorAnnotation != "" {
+		t.selector, err = parseNamespaceSelector(selectorAnnotation)
+		if err != nil {
+			return targets{}, err
+		}
+	}
+	return t, nil
+}
+
+// needsLookup checks if the targets can only be resolved against
+// the namespaces in the cluster
+func (t targets) needsLookup() bool {
+	return t.all || t.selector != nil || len(t.included.patterns) > 0
+}
+
+// matches checks if the namespace is targeted
+func (t targets) matches(namespace *v1.Namespace) bool {
+	if t.excluded.contains(namespace.Name) {
+		return false
+	}
+	return t.all ||
+		t.included.contains(namespace.Name) ||
+		(t.selector != nil && t.selector.Matches(labels.Set(namespace.Labels)))
+}
+
 // ParseOrFetchNamespaces parses the namespaces of a secret from the specified
-// annotation and retrives either all namespaces (if `*` is in the
-// field of the annotation) or the specified namespaces. An empty annotation yields no namespaces.
+// annotations and retrives either all namespaces (if `*` is in the
+// field of the namespaces annotation) or the specified namespaces. Glob and
+// regex entries of the namespaces annotation are resolved against the
+// namespaces in the cluster. If the namespace selector annotation is set,
+// every namespace whose labels match the selector is added to the result,
+// i.e. the union of both annotations is returned.
+// Namespaces matched by the exclude annotation or by the excluded entries,
+// which are configured globally, are always removed from the result.
+// Secrets without either the namespaces or selector annotation yield no namespaces.
 func ParseOrFetchNamespaces(
 	ctx context.Context,
 	client corev1.NamespacesGetter,
 	objAnnotations map[string]string,
+	excluded []string,
 ) ([]string, error) {
-	// parse the annotations
-	namespaces, err := parseNamespaces(
-		objAnnotations[NamespaceAnnotation])
+	t, err := parseTargets(objAnnotations, excluded)
 	if err != nil {
 		return []string{}, err
-	} else if len(namespaces) == 0 {
-		found, err := client.Namespaces().List(ctx, metav1.ListOptions{})
-		if err != nil {
-			return []string{}, errors.Wrap(err, "unable to list namespaces")
+	}
+
+	namespaces := []string{}
+	namespaceSet := map[string]struct{}{}
+	for _, ns := range t.included.names {
+		namespaceSet[ns] = struct{}{}
+		if !t.excluded.contains(ns) {
+			namespaces = append(namespaces, ns)
 		}
-		for _, namespace := range found.Items {
+	}
+
+	// plain namespace names don't need to be looked up
+	if !t.needsLookup() {
+		return namespaces, nil
+	}
+
+	found, err := client.Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return []string{}, errors.Wrap(err, "unable to list namespaces")
+	}
+
+	for i := range found.Items {
+		namespace := &found.Items[i]
+		if _, ok := namespaceSet[namespace.Name]; ok {
+			continue
+		}
+		// secrets can't be created in namespaces that are being deleted
+		if namespace.Status.Phase == v1.NamespaceTerminating {
+			continue
+		}
+		if t.matches(namespace) {
+			namespaceSet[namespace.Name] = struct{}{}
 			namespaces = append(namespaces, namespace.Name)
 		}
 	}
 	return namespaces, nil
 }
 
+// TargetsNamespace checks if a secret with the given annotations is
+// reflected to the namespace, following the same rules as
+// ParseOrFetchNamespaces without looking up any other namespaces.
+func TargetsNamespace(
+	objAnnotations map[string]string,
+	namespace *v1.Namespace,
+	excluded []string,
+) (bool, error) {
+	t, err := parseTargets(objAnnotations, excluded)
+	if err != nil {
+		return false, err
+	}
+	return t.matches(namespace), nil
+}
+
+// parseNamespaceSelector parses the namespace selector annotation as a label selector
+func parseNamespaceSelector(str string) (labels.Selector, error) {
+	selector, err := labels.Parse(str)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse namespace selector")
+	}
+	return selector, nil
+}
+
 // parseNamespaces fetches the list of namespaces from the correct annotation
 func parseNamespaces(str string) ([]string, error) {
 	if str == "" {
@@ -84,8 +608,17 @@ func parseNamespaces(str string) ([]string, error) {
 		return []string{}, nil
 	}
 
-	// split and trim spaces, and dedupe
+	return splitNamespaces(str), nil
+}
+
+// splitNamespaces splits a comma separated namespace list, trimming
+// spaces and deduping the entries
+func splitNamespaces(str string) []string {
 	namespaces := []string{}
+	if str == "" {
+		return namespaces
+	}
+
 	namespaceSet := map[string]struct{}{}
 	split := strings.Split(str, ",")
 	for _, ns := range split {
@@ -97,5 +630,5 @@ func parseNamespaces(str string) ([]string, error) {
 		namespaces = append(namespaces, trimmed)
 	}
 
-	return namespaces, nil
+	return namespaces
 }
@@ -2,13 +2,16 @@ package annotations
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 )
@@ -51,6 +54,405 @@ func TestCanOperate(t *testing.T) {
 	}
 }
 
+func TestShouldReflect(t *testing.T) {
+	assert.True(t, ShouldReflect(map[string]string{ReflectAnnotation: "true"}))
+	assert.False(t, ShouldReflect(map[string]string{ReflectAnnotation: "false"}))
+	assert.False(t, ShouldReflect(map[string]string{}))
+	assert.False(t, ShouldReflect(nil))
+}
+
+func TestShouldPrune(t *testing.T) {
+	tests := []struct {
+		descrip string
+		ann     map[string]string
+		def     bool
+		expect  bool
+	}{
+		{"no annotation falls back to false", map[string]string{}, false, false},
+		{"no annotation falls back to true", map[string]string{}, true, true},
+		{"annotation opts in", map[string]string{PruneAnnotation: "true"}, false, true},
+		{"annotation opts out", map[string]string{PruneAnnotation: "false"}, true, false},
+		{"invalid annotation falls back", map[string]string{PruneAnnotation: "yes"}, true, true},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expect, ShouldPrune(test.ann, test.def))
+		})
+	}
+}
+
+func TestRemoveSourceAnnotations(t *testing.T) {
+	ann := map[string]string{
+		ReflectAnnotation:                 "true",
+		NamespaceAnnotation:               "*",
+		NamespaceSelectorAnnotation:       "env=prod",
+		NamespaceExcludeAnnotation:        "kube-system",
+		PruneAnnotation:                   "true",
+		AllowPullAnnotation:               "*",
+		AttachToServiceAccountsAnnotation: "default",
+		ProjectToConfigMapAnnotation:      "ca.crt",
+		KeysAnnotation:                    "ca.crt",
+		KeysExcludeAnnotation:             "tls.key",
+		"custom.annotation.k8s.io":        "very-custom",
+	}
+	RemoveSourceAnnotations(ann)
+	assert.Equal(t, map[string]string{"custom.annotation.k8s.io": "very-custom"}, ann)
+}
+
+func TestServiceAccounts(t *testing.T) {
+	tests := []struct {
+		descrip string
+		ann     map[string]string
+		expect  []string
+	}{
+		{"no annotation attaches to nothing", map[string]string{}, []string{}},
+		{
+			"parses a list of service accounts",
+			map[string]string{AttachToServiceAccountsAnnotation: "default, builder,default"},
+			[]string{"default", "builder"},
+		},
+		{
+			"ignores empty entries",
+			map[string]string{AttachToServiceAccountsAnnotation: "default,,"},
+			[]string{"default"},
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expect, ServiceAccounts(test.ann))
+		})
+	}
+}
+
+func TestAttachedSecrets(t *testing.T) {
+	assert.Equal(t, []string{}, AttachedSecrets(map[string]string{}))
+	assert.Equal(t,
+		[]string{"thing", "other"},
+		AttachedSecrets(map[string]string{AttachedSecretsAnnotation: "thing,other,"}))
+}
+
+func TestProjectedKeys(t *testing.T) {
+	assert.Equal(t, []string{}, ProjectedKeys(map[string]string{}))
+	assert.Equal(t,
+		[]string{"ca.crt", "tls.crt"},
+		ProjectedKeys(map[string]string{ProjectToConfigMapAnnotation: "ca.crt, tls.crt,"}))
+}
+
+func TestKeepsKey(t *testing.T) {
+	tests := []struct {
+		descrip string
+		ann     map[string]string
+		key     string
+		keeps   bool
+	}{
+		{"no annotations keep every key", map[string]string{}, "tls.key", true},
+		{"keeps an included key", map[string]string{KeysAnnotation: "ca.crt, tls.crt"}, "tls.crt", true},
+		{"drops a key that is not included", map[string]string{KeysAnnotation: "ca.crt"}, "tls.key", false},
+		{"drops every key if nothing is included", map[string]string{KeysAnnotation: ""}, "ca.crt", false},
+		{"drops an excluded key", map[string]string{KeysExcludeAnnotation: "tls.key"}, "tls.key", false},
+		{"keeps a key that is not excluded", map[string]string{KeysExcludeAnnotation: "tls.key"}, "ca.crt", true},
+		{
+			"exclusions win over inclusions",
+			map[string]string{KeysAnnotation: "tls.key", KeysExcludeAnnotation: "tls.key"},
+			"tls.key",
+			false,
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.keeps, KeepsKey(test.ann, test.key))
+		})
+	}
+}
+
+func TestKeyMap(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		expected map[string]string
+		err      bool
+	}{
+		{"no annotation maps nothing", map[string]string{}, map[string]string{}, false},
+		{
+			"parses the entries",
+			map[string]string{KeyMapAnnotation: "password=POSTGRES_PASSWORD, username = POSTGRES_USER"},
+			map[string]string{"password": "POSTGRES_PASSWORD", "username": "POSTGRES_USER"},
+			false,
+		},
+		{"entries need a new name", map[string]string{KeyMapAnnotation: "password"}, nil, true},
+		{"entries can't be empty", map[string]string{KeyMapAnnotation: "password="}, nil, true},
+		{"new names must be valid keys", map[string]string{KeyMapAnnotation: "password=pass word"}, nil, true},
+		{"keys can't be mapped twice", map[string]string{KeyMapAnnotation: "password=a,password=b"}, nil, true},
+		{"new names can't collide", map[string]string{KeyMapAnnotation: "password=a,username=a"}, nil, true},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			keyMap, err := KeyMap(test.ann)
+			if test.err {
+				assert.ErrorIs(t, err, ErrorInvalidKeyMap)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, keyMap)
+		})
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		expected map[string]string
+		err      bool
+	}{
+		{"no annotation has no templates", map[string]string{}, map[string]string{}, false},
+		{
+			"parses the templates",
+			map[string]string{TemplateAnnotation: `{"DSN": "postgres://{{ .user }}@{{ .host }}"}`},
+			map[string]string{"DSN": "postgres://{{ .user }}@{{ .host }}"},
+			false,
+		},
+		{"templates must be a JSON object", map[string]string{TemplateAnnotation: `{{ .user }}`}, nil, true},
+		{"keys must be valid", map[string]string{TemplateAnnotation: `{"not valid": "x"}`}, nil, true},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			templates, err := Templates(test.ann)
+			if test.err {
+				assert.ErrorIs(t, err, ErrorInvalidTemplate)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, templates)
+		})
+	}
+}
+
+func TestParseTargetNames(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		expected TargetNames
+		err      bool
+	}{
+		{"no annotation names nothing", map[string]string{}, TargetNames{}, false},
+		{"names every namespace", map[string]string{TargetNameAnnotation: "db-creds"}, TargetNames{"": "db-creds"}, false},
+		{
+			"names single namespaces",
+			map[string]string{TargetNameAnnotation: "ns-a:db-creds, ns-b:legacy-db,creds"},
+			TargetNames{"ns-a": "db-creds", "ns-b": "legacy-db", "": "creds"},
+			false,
+		},
+		{"names must be valid", map[string]string{TargetNameAnnotation: "ns-a:Not_Valid"}, nil, true},
+		{"names can't be empty", map[string]string{TargetNameAnnotation: "ns-a:"}, nil, true},
+		{"namespaces can't be named twice", map[string]string{TargetNameAnnotation: "ns-a:a,ns-a:b"}, nil, true},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			names, err := ParseTargetNames(test.ann)
+			if test.err {
+				assert.ErrorIs(t, err, ErrorInvalidTargetName)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestTargetNamesName(t *testing.T) {
+	names := TargetNames{"ns-a": "db-creds", "": "creds"}
+	assert.Equal(t, "db-creds", names.Name("ns-a", "secret"))
+	assert.Equal(t, "creds", names.Name("ns-b", "secret"))
+	assert.Equal(t, "secret", TargetNames{}.Name("ns-b", "secret"))
+}
+
+func TestTargetType(t *testing.T) {
+	secretType, err := TargetType(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.SecretType(""), secretType)
+
+	secretType, err = TargetType(map[string]string{TargetTypeAnnotation: " kubernetes.io/tls "})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.SecretTypeTLS, secretType)
+
+	_, err = TargetType(map[string]string{TargetTypeAnnotation: "kubernetes.io/not a type"})
+	assert.ErrorIs(t, err, ErrorInvalidTargetType)
+}
+
+func TestAggregateTo(t *testing.T) {
+	name, err := AggregateTo(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", name)
+
+	name, err = AggregateTo(map[string]string{AggregateToAnnotation: " registry-creds "})
+	assert.Nil(t, err)
+	assert.Equal(t, "registry-creds", name)
+
+	_, err = AggregateTo(map[string]string{AggregateToAnnotation: "Registry_Creds"})
+	assert.ErrorIs(t, err, ErrorInvalidAggregate)
+}
+
+func TestOverrideOf(t *testing.T) {
+	source, err := OverrideOf(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", source)
+
+	source, err = OverrideOf(map[string]string{OverrideOfAnnotation: " source/db-creds "})
+	assert.Nil(t, err)
+	assert.Equal(t, "source/db-creds", source)
+
+	for _, invalid := range []string{"db-creds", "/db-creds", "source/", "source/db/creds", "Source/db-creds"} {
+		_, err = OverrideOf(map[string]string{OverrideOfAnnotation: invalid})
+		assert.ErrorIs(t, err, ErrorInvalidOverride, invalid)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	priority, err := Priority(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, priority)
+
+	priority, err = Priority(map[string]string{PriorityAnnotation: " -10 "})
+	assert.Nil(t, err)
+	assert.Equal(t, -10, priority)
+
+	_, err = Priority(map[string]string{PriorityAnnotation: "high"})
+	assert.ErrorIs(t, err, ErrorInvalidPriority)
+}
+
+func TestIsReflectionOf(t *testing.T) {
+	renamed := map[string]string{
+		ReflectedFromAnnotation: "source",
+		ReflectedNameAnnotation: "secret",
+	}
+	assert.True(t, IsReflectionOf(renamed, "db-creds", "source", "secret"))
+	assert.False(t, IsReflectionOf(renamed, "db-creds", "source", "db-creds"))
+	assert.False(t, IsReflectionOf(renamed, "db-creds", "other", "secret"))
+
+	unnamed := map[string]string{ReflectedFromAnnotation: "source"}
+	assert.True(t, IsReflectionOf(unnamed, "secret", "source", "secret"))
+	assert.False(t, IsReflectionOf(unnamed, "db-creds", "source", "secret"))
+}
+
+func TestReflectionOf(t *testing.T) {
+	value := ReflectionOf("source", strings.Repeat("a", 253))
+	assert.Empty(t, validation.IsValidLabelValue(value))
+	assert.Equal(t, value, ReflectionOf("source", strings.Repeat("a", 253)))
+	// the separator keeps namespaces and names from running into each other
+	assert.NotEqual(t, ReflectionOf("ab", "c"), ReflectionOf("a", "bc"))
+
+	selector, err := labels.Parse(ReflectionOfSelector("source", "secret"))
+	require.Nil(t, err)
+	assert.True(t, selector.Matches(labels.Set{ReflectionOfLabel: ReflectionOf("source", "secret")}))
+	assert.False(t, selector.Matches(labels.Set{ReflectionOfLabel: ReflectionOf("source", "other")}))
+}
+
+func TestIsReflectedFrom(t *testing.T) {
+	assert.True(t, IsReflectedFrom(map[string]string{ReflectedFromAnnotation: "source"}, "source"))
+	assert.False(t, IsReflectedFrom(map[string]string{ReflectedFromAnnotation: "other"}, "source"))
+	assert.False(t, IsReflectedFrom(map[string]string{}, "source"))
+}
+
+func TestAcceptsSecret(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		required bool
+		accepts  bool
+		err      bool
+	}{
+		{
+			"no annotation accepts when consent is not required",
+			map[string]string{},
+			false,
+			true,
+			false,
+		},
+		{
+			"no annotation rejects when consent is required",
+			map[string]string{},
+			true,
+			false,
+			false,
+		},
+		{
+			"accepts a listed secret",
+			map[string]string{AcceptFromAnnotation: "shared/registry-creds,shared/db"},
+			true,
+			true,
+			false,
+		},
+		{
+			"accepts secrets matching a glob",
+			map[string]string{AcceptFromAnnotation: "cert-system/*"},
+			false,
+			false,
+			false,
+		},
+		{
+			"accepts secrets matching a regex",
+			map[string]string{AcceptFromAnnotation: "regex:^shared/registry-"},
+			false,
+			true,
+			false,
+		},
+		{
+			"rejects unlisted secrets even if consent is not required",
+			map[string]string{AcceptFromAnnotation: "shared/db"},
+			false,
+			false,
+			false,
+		},
+		{
+			"an empty annotation rejects everything",
+			map[string]string{AcceptFromAnnotation: ""},
+			false,
+			false,
+			false,
+		},
+		{
+			"an invalid annotation is an error",
+			map[string]string{AcceptFromAnnotation: "shared/[reg"},
+			false,
+			false,
+			true,
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			accepts, err := AcceptsSecret(test.ann, "shared", "registry-creds", test.required)
+			if test.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, test.accepts, accepts)
+		})
+	}
+}
+
 func TestParseOrFetchNamespaces(t *testing.T) {
 	tests := []struct {
 		descrip     string
@@ -138,7 +540,8 @@ func TestParseOrFetchNamespaces(t *testing.T) {
 			namespaces, err := ParseOrFetchNamespaces(
 				context.Background(),
 				fakeCore,
-				test.annotations)
+				test.annotations,
+				[]string{})
 			assert.Equal(t, test.namespaces, namespaces)
 			if test.listErr != nil {
 				assert.NotNil(t, err)
@@ -149,6 +552,409 @@ func TestParseOrFetchNamespaces(t *testing.T) {
 	}
 }
 
+func TestParseOrFetchNamespacesSkipsTerminating(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		annotation string
+		namespaces []string
+	}{
+		{
+			"skips terminating namespaces when fetching all namespaces",
+			"*",
+			[]string{"default"},
+		},
+		{
+			"skips terminating namespaces matched by a pattern",
+			"d*",
+			[]string{"default"},
+		},
+		{
+			"keeps terminating namespaces that are listed by name",
+			"default,deleted",
+			[]string{"default", "deleted"},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			active := &v1.Namespace{}
+			active.Name = "default"
+			active.Status.Phase = v1.NamespaceActive
+			terminating := &v1.Namespace{}
+			terminating.Name = "deleted"
+			terminating.Status.Phase = v1.NamespaceTerminating
+
+			namespaces, err := ParseOrFetchNamespaces(
+				context.Background(),
+				fake.NewSimpleClientset(active, terminating).CoreV1(),
+				map[string]string{NamespaceAnnotation: test.annotation},
+				[]string{})
+			require.Nil(t, err)
+			assert.ElementsMatch(t, test.namespaces, namespaces)
+		})
+	}
+}
+
+func labelledNamespaces() []runtime.Object {
+	objs := []runtime.Object{}
+	for name, nsLabels := range map[string]map[string]string{
+		"default":          {},
+		"payments":         {"team": "payments", "env": "prod"},
+		"payments-staging": {"team": "payments", "env": "staging"},
+		"shipping":         {"team": "shipping", "env": "prod"},
+		"ci-12":            {},
+		"ci-main":          {},
+	} {
+		ns := &v1.Namespace{}
+		ns.Name = name
+		ns.Labels = nsLabels
+		objs = append(objs, ns)
+	}
+	return objs
+}
+
+func TestParseOrFetchNamespacesWithSelector(t *testing.T) {
+	tests := []struct {
+		descrip     string
+		annotations map[string]string
+		namespaces  []string
+		err         bool
+	}{
+		{
+			"selects namespaces by label",
+			map[string]string{
+				NamespaceSelectorAnnotation: "env=prod",
+			},
+			[]string{"payments", "shipping"},
+			false,
+		},
+		{
+			"selects namespaces with set based requirements",
+			map[string]string{
+				NamespaceSelectorAnnotation: "team=payments,env in (prod,staging)",
+			},
+			[]string{"payments", "payments-staging"},
+			false,
+		},
+		{
+			"takes the union of the namespaces and the selector",
+			map[string]string{
+				NamespaceAnnotation:         "default,payments",
+				NamespaceSelectorAnnotation: "env=prod",
+			},
+			[]string{"default", "payments", "shipping"},
+			false,
+		},
+		{
+			"selects nothing when no labels match",
+			map[string]string{
+				NamespaceSelectorAnnotation: "env=dev",
+			},
+			[]string{},
+			false,
+		},
+		{
+			"`*` takes precedence over the selector",
+			map[string]string{
+				NamespaceAnnotation:         "*",
+				NamespaceSelectorAnnotation: "env=dev",
+			},
+			[]string{"default", "payments", "payments-staging", "shipping", "ci-12", "ci-main"},
+			false,
+		},
+		{
+			"fails on an invalid selector",
+			map[string]string{
+				NamespaceSelectorAnnotation: "env in prod",
+			},
+			[]string{},
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			namespaces, err := ParseOrFetchNamespaces(
+				context.Background(),
+				fake.NewSimpleClientset(labelledNamespaces()...).CoreV1(),
+				test.annotations,
+				[]string{})
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			assert.ElementsMatch(t, test.namespaces, namespaces)
+		})
+	}
+}
+
+func TestParseOrFetchNamespacesWithPatterns(t *testing.T) {
+	tests := []struct {
+		descrip     string
+		annotations map[string]string
+		namespaces  []string
+		err         bool
+	}{
+		{
+			"resolves globs against the namespaces",
+			map[string]string{
+				NamespaceAnnotation: "payments*",
+			},
+			[]string{"payments", "payments-staging"},
+			false,
+		},
+		{
+			"resolves regexes against the namespaces",
+			map[string]string{
+				NamespaceAnnotation: "regex:^ci-[0-9]+$",
+			},
+			[]string{"ci-12"},
+			false,
+		},
+		{
+			"mixes names, globs and regexes",
+			map[string]string{
+				NamespaceAnnotation: "default, ship?ing, regex:^ci-, not-created",
+			},
+			[]string{"default", "not-created", "shipping", "ci-12", "ci-main"},
+			false,
+		},
+		{
+			"takes the union of patterns and the selector",
+			map[string]string{
+				NamespaceAnnotation:         "ci-*",
+				NamespaceSelectorAnnotation: "team=shipping",
+			},
+			[]string{"ci-12", "ci-main", "shipping"},
+			false,
+		},
+		{
+			"fails on an invalid glob",
+			map[string]string{
+				NamespaceAnnotation: "default,team-[",
+			},
+			[]string{},
+			true,
+		},
+		{
+			"fails on an invalid regex",
+			map[string]string{
+				NamespaceAnnotation: "default,regex:ci-(",
+			},
+			[]string{},
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			namespaces, err := ParseOrFetchNamespaces(
+				context.Background(),
+				fake.NewSimpleClientset(labelledNamespaces()...).CoreV1(),
+				test.annotations,
+				[]string{})
+			if test.err {
+				assert.NotNil(t, err)
+				assert.Equal(t, test.namespaces, namespaces)
+				return
+			}
+			require.Nil(t, err)
+			assert.ElementsMatch(t, test.namespaces, namespaces)
+		})
+	}
+}
+
+func TestParseOrFetchNamespacesWithExclusions(t *testing.T) {
+	tests := []struct {
+		descrip     string
+		annotations map[string]string
+		excluded    []string
+		namespaces  []string
+		err         bool
+	}{
+		{
+			"excludes namespaces from `*`",
+			map[string]string{
+				NamespaceAnnotation:        "*",
+				NamespaceExcludeAnnotation: "default, ci-*",
+			},
+			[]string{},
+			[]string{"payments", "payments-staging", "shipping"},
+			false,
+		},
+		{
+			"excludes explicitly listed namespaces",
+			map[string]string{
+				NamespaceAnnotation:        "default,shipping,logging",
+				NamespaceExcludeAnnotation: "regex:^log",
+			},
+			[]string{},
+			[]string{"default", "shipping"},
+			false,
+		},
+		{
+			"excludes namespaces from the selector",
+			map[string]string{
+				NamespaceSelectorAnnotation: "env=prod",
+				NamespaceExcludeAnnotation:  "shipping",
+			},
+			[]string{},
+			[]string{"payments"},
+			false,
+		},
+		{
+			"global exclusions always win",
+			map[string]string{
+				NamespaceAnnotation: "default,payments*",
+			},
+			[]string{"default", "regex:-staging$"},
+			[]string{"payments"},
+			false,
+		},
+		{
+			"combines the annotation and global exclusions",
+			map[string]string{
+				NamespaceAnnotation:        "*",
+				NamespaceExcludeAnnotation: "payments*",
+			},
+			[]string{"ci-*", "default"},
+			[]string{"shipping"},
+			false,
+		},
+		{
+			"fails on an invalid exclusion",
+			map[string]string{
+				NamespaceAnnotation:        "*",
+				NamespaceExcludeAnnotation: "regex:(",
+			},
+			[]string{},
+			[]string{},
+			true,
+		},
+		{
+			"fails on an invalid global exclusion",
+			map[string]string{
+				NamespaceAnnotation: "default",
+			},
+			[]string{"kube-["},
+			[]string{},
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			namespaces, err := ParseOrFetchNamespaces(
+				context.Background(),
+				fake.NewSimpleClientset(labelledNamespaces()...).CoreV1(),
+				test.annotations,
+				test.excluded)
+			if test.err {
+				assert.NotNil(t, err)
+				assert.Equal(t, test.namespaces, namespaces)
+				return
+			}
+			require.Nil(t, err)
+			assert.ElementsMatch(t, test.namespaces, namespaces)
+		})
+	}
+}
+
+func TestTargetsNamespace(t *testing.T) {
+	namespace := &v1.Namespace{}
+	namespace.Name = "payments"
+	namespace.Labels = map[string]string{"env": "prod"}
+
+	tests := []struct {
+		descrip     string
+		annotations map[string]string
+		excluded    []string
+		targets     bool
+		err         error
+	}{
+		{
+			"no annotations is an error",
+			map[string]string{},
+			[]string{},
+			false,
+			ErrorNoNamespace,
+		},
+		{
+			"targets a listed namespace",
+			map[string]string{NamespaceAnnotation: "default,payments"},
+			[]string{},
+			true,
+			nil,
+		},
+		{
+			"does not target an unlisted namespace",
+			map[string]string{NamespaceAnnotation: "default,shipping"},
+			[]string{},
+			false,
+			nil,
+		},
+		{
+			"targets a namespace for `*`",
+			map[string]string{NamespaceAnnotation: "*"},
+			[]string{},
+			true,
+			nil,
+		},
+		{
+			"targets a namespace matching a glob",
+			map[string]string{NamespaceAnnotation: "pay*"},
+			[]string{},
+			true,
+			nil,
+		},
+		{
+			"targets a namespace matching the selector",
+			map[string]string{NamespaceSelectorAnnotation: "env=prod"},
+			[]string{},
+			true,
+			nil,
+		},
+		{
+			"does not target a namespace not matching the selector",
+			map[string]string{NamespaceSelectorAnnotation: "env=dev"},
+			[]string{},
+			false,
+			nil,
+		},
+		{
+			"does not target an excluded namespace",
+			map[string]string{
+				NamespaceAnnotation:        "*",
+				NamespaceExcludeAnnotation: "payments",
+			},
+			[]string{},
+			false,
+			nil,
+		},
+		{
+			"does not target a globally excluded namespace",
+			map[string]string{NamespaceAnnotation: "payments"},
+			[]string{"pay*"},
+			false,
+			nil,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			targets, err := TargetsNamespace(test.annotations, namespace, test.excluded)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.targets, targets)
+		})
+	}
+}
+
 func TestParseNamespaces(t *testing.T) {
 	tests := []struct {
 		d   string
@@ -192,3 +998,11 @@ func TestParseNamespaces(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitNamespaces(t *testing.T) {
+	assert.Equal(t, []string{}, splitNamespaces(""))
+	assert.Equal(t, []string{"*"}, splitNamespaces("*"))
+	assert.Equal(t,
+		[]string{"kube-system", "kube-*"},
+		splitNamespaces("kube-system, kube-*,kube-system"))
+}
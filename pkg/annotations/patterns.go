@@ -0,0 +1,107 @@
+package annotations
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RegexPrefix marks an entry of a namespace list as a regular
+// expression rather than a namespace name or glob
+const RegexPrefix = "regex:"
+
+// globChars are the characters which make an entry of a namespace
+// list a glob rather than a namespace name
+const globChars = "*?["
+
+// pattern is a glob or regular expression entry of a namespace list
+// which is resolved against the namespaces in the cluster
+type pattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// matches checks if the namespace name matches the pattern
+func (p pattern) matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	// the glob is validated on compilation so the error can be ignored
+	ok, _ := path.Match(p.glob, name)
+	return ok
+}
+
+// compilePattern compiles a single entry of a namespace list. The
+// boolean is false if the entry is a plain namespace name.
+func compilePattern(entry string) (pattern, bool, error) {
+	if strings.HasPrefix(entry, RegexPrefix) {
+		regex, err := regexp.Compile(strings.TrimPrefix(entry, RegexPrefix))
+		if err != nil {
+			return pattern{}, false, errors.Wrapf(err, "invalid namespace regex %q", entry)
+		}
+		return pattern{regex: regex}, true, nil
+	}
+
+	if !strings.ContainsAny(entry, globChars) {
+		return pattern{}, false, nil
+	}
+
+	if _, err := path.Match(entry, ""); err != nil {
+		return pattern{}, false, errors.Wrapf(err, "invalid namespace glob %q", entry)
+	}
+	return pattern{glob: entry}, true, nil
+}
+
+// namespaceList is a compiled namespace list made up of plain
+// namespace names and patterns
+type namespaceList struct {
+	names    []string
+	patterns []pattern
+}
+
+// contains checks if the namespace is named in the list or matches
+// any of its patterns
+func (l namespaceList) contains(name string) bool {
+	for _, n := range l.names {
+		if n == name {
+			return true
+		}
+	}
+	for _, p := range l.patterns {
+		if p.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileNamespaces splits the entries of a namespace list into the
+// plain namespace names and the patterns which have to be resolved
+// against the namespaces in the cluster
+func compileNamespaces(entries []string) (namespaceList, error) {
+	list := namespaceList{
+		names:    []string{},
+		patterns: []pattern{},
+	}
+	for _, entry := range entries {
+		p, isPattern, err := compilePattern(entry)
+		if err != nil {
+			return namespaceList{}, err
+		}
+		if isPattern {
+			list.patterns = append(list.patterns, p)
+			continue
+		}
+		list.names = append(list.names, entry)
+	}
+	return list, nil
+}
+
+// ValidateNamespaces checks that every entry of a namespace list is
+// either a namespace name or a valid glob or regular expression
+func ValidateNamespaces(entries []string) error {
+	_, err := compileNamespaces(entries)
+	return err
+}
@@ -0,0 +1,117 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		descrip   string
+		entry     string
+		isPattern bool
+		err       bool
+		matches   []string
+		misses    []string
+	}{
+		{
+			"plain names are not patterns",
+			"default",
+			false,
+			false,
+			[]string{},
+			[]string{},
+		},
+		{
+			"globs match by prefix",
+			"team-*",
+			true,
+			false,
+			[]string{"team-a", "team-"},
+			[]string{"team", "other-team-a"},
+		},
+		{
+			"globs match single characters",
+			"ns-?",
+			true,
+			false,
+			[]string{"ns-1", "ns-a"},
+			[]string{"ns-12", "ns-"},
+		},
+		{
+			"regexes are matched",
+			"regex:^ci-[0-9]+$",
+			true,
+			false,
+			[]string{"ci-1", "ci-123"},
+			[]string{"ci-main", "xci-1"},
+		},
+		{
+			"regexes are unanchored",
+			"regex:ci",
+			true,
+			false,
+			[]string{"ci", "some-ci-ns"},
+			[]string{"default"},
+		},
+		{
+			"invalid globs error",
+			"team-[a",
+			false,
+			true,
+			[]string{},
+			[]string{},
+		},
+		{
+			"invalid regexes error",
+			"regex:[a",
+			false,
+			true,
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			p, isPattern, err := compilePattern(test.entry)
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, test.isPattern, isPattern)
+			for _, name := range test.matches {
+				assert.True(t, p.matches(name), name)
+			}
+			for _, name := range test.misses {
+				assert.False(t, p.matches(name), name)
+			}
+		})
+	}
+}
+
+func TestCompileNamespaces(t *testing.T) {
+	list, err := compileNamespaces(
+		[]string{"default", "team-*", "regex:^ci-", "monitoring"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"default", "monitoring"}, list.names)
+	assert.Len(t, list.patterns, 2)
+	assert.True(t, list.contains("team-a"))
+	assert.True(t, list.contains("ci-1"))
+	assert.True(t, list.contains("default"))
+	assert.False(t, list.contains("logging"))
+
+	_, err = compileNamespaces([]string{"default", "regex:("})
+	assert.NotNil(t, err)
+}
+
+func TestValidateNamespaces(t *testing.T) {
+	assert.Nil(t, ValidateNamespaces([]string{}))
+	assert.Nil(t, ValidateNamespaces([]string{"kube-*", "regex:^ci-", "default"}))
+	assert.NotNil(t, ValidateNamespaces([]string{"kube-["}))
+}
@@ -0,0 +1,76 @@
+package annotations
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorInvalidPullSource is used when an entry of the pull annotation
+// is not of the form `namespace/name`
+var ErrorInvalidPullSource = errors.New("invalid pull source")
+
+// PullSources parses the pull annotation of a namespace into the
+// `namespace/name` keys of the secrets it pulls
+func PullSources(nsAnnotations map[string]string) ([]string, error) {
+	sources := []string{}
+	for _, entry := range splitNamespaces(nsAnnotations[PullAnnotation]) {
+		namespace, name, ok := strings.Cut(entry, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return []string{}, errors.Wrapf(ErrorInvalidPullSource, "%q", entry)
+		}
+		sources = append(sources, entry)
+	}
+	return sources, nil
+}
+
+// PullsSecret checks if a namespace, given its annotations, pulls the
+// secret. Namespaces with an invalid pull annotation pull nothing.
+func PullsSecret(
+	nsAnnotations map[string]string,
+	secretNamespace string,
+	secretName string,
+) bool {
+	sources, err := PullSources(nsAnnotations)
+	if err != nil {
+		return false
+	}
+	for _, source := range sources {
+		if source == secretNamespace+"/"+secretName {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPullable checks if a secret can be pulled into any namespace
+func IsPullable(objAnnotations map[string]string) bool {
+	_, ok := objAnnotations[AllowPullAnnotation]
+	return ok
+}
+
+// AllowsPull checks if the secret, given its annotations, may be pulled
+// into the namespace. The allow pull annotation is a comma separated list
+// of namespace names, globs and regexes. Secrets without the annotation
+// can't be pulled. Namespaces matched by the excluded entries, which are
+// configured globally, can never pull the secret.
+func AllowsPull(
+	objAnnotations map[string]string,
+	namespace string,
+	excluded []string,
+) (bool, error) {
+	allowPull, ok := objAnnotations[AllowPullAnnotation]
+	if !ok {
+		return false, nil
+	}
+
+	allowed, err := compileNamespaces(splitNamespaces(allowPull))
+	if err != nil {
+		return false, errors.Wrap(err, "invalid allow pull annotation")
+	}
+	denied, err := compileNamespaces(excluded)
+	if err != nil {
+		return false, err
+	}
+	return allowed.contains(namespace) && !denied.contains(namespace), nil
+}
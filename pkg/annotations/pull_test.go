@@ -0,0 +1,153 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullSources(t *testing.T) {
+	tests := []struct {
+		descrip string
+		ann     map[string]string
+		sources []string
+		err     bool
+	}{
+		{
+			"no annotation pulls nothing",
+			map[string]string{},
+			[]string{},
+			false,
+		},
+		{
+			"parses the listed secrets",
+			map[string]string{PullAnnotation: "shared/registry-creds, shared/ca-bundle"},
+			[]string{"shared/registry-creds", "shared/ca-bundle"},
+			false,
+		},
+		{
+			"deduplicates the listed secrets",
+			map[string]string{PullAnnotation: "shared/ca-bundle,shared/ca-bundle"},
+			[]string{"shared/ca-bundle"},
+			false,
+		},
+		{
+			"fails on an entry without a namespace",
+			map[string]string{PullAnnotation: "shared/ca-bundle,registry-creds"},
+			[]string{},
+			true,
+		},
+		{
+			"fails on an entry without a name",
+			map[string]string{PullAnnotation: "shared/"},
+			[]string{},
+			true,
+		},
+		{
+			"fails on an entry with too many parts",
+			map[string]string{PullAnnotation: "shared/ca/bundle"},
+			[]string{},
+			true,
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			sources, err := PullSources(test.ann)
+			assert.Equal(t, test.sources, sources)
+			if test.err {
+				assert.True(t, errors.Is(err, ErrorInvalidPullSource))
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestPullsSecret(t *testing.T) {
+	ann := map[string]string{PullAnnotation: "shared/registry-creds,shared/ca-bundle"}
+	assert.True(t, PullsSecret(ann, "shared", "ca-bundle"))
+	assert.False(t, PullsSecret(ann, "other", "ca-bundle"))
+	assert.False(t, PullsSecret(map[string]string{}, "shared", "ca-bundle"))
+	assert.False(t, PullsSecret(
+		map[string]string{PullAnnotation: "shared/ca-bundle,invalid"}, "shared", "ca-bundle"))
+}
+
+func TestAllowsPull(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		excluded []string
+		allows   bool
+		err      bool
+	}{
+		{
+			"no annotation does not allow pulls",
+			map[string]string{},
+			[]string{},
+			false,
+			false,
+		},
+		{
+			"allows every namespace",
+			map[string]string{AllowPullAnnotation: "*"},
+			[]string{},
+			true,
+			false,
+		},
+		{
+			"allows a listed namespace",
+			map[string]string{AllowPullAnnotation: "team-a,team-b"},
+			[]string{},
+			true,
+			false,
+		},
+		{
+			"allows a namespace matching a pattern",
+			map[string]string{AllowPullAnnotation: "regex:^team-"},
+			[]string{},
+			true,
+			false,
+		},
+		{
+			"does not allow an unlisted namespace",
+			map[string]string{AllowPullAnnotation: "team-a"},
+			[]string{},
+			false,
+			false,
+		},
+		{
+			"does not allow an excluded namespace",
+			map[string]string{AllowPullAnnotation: "*"},
+			[]string{"team-*"},
+			false,
+			false,
+		},
+		{
+			"fails on an invalid pattern",
+			map[string]string{AllowPullAnnotation: "regex:("},
+			[]string{},
+			false,
+			true,
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			allows, err := AllowsPull(test.ann, "team-b", test.excluded)
+			assert.Equal(t, test.allows, allows)
+			assert.Equal(t, test.err, err != nil)
+		})
+	}
+}
+
+func TestIsPullable(t *testing.T) {
+	assert.True(t, IsPullable(map[string]string{AllowPullAnnotation: "*"}))
+	assert.False(t, IsPullable(map[string]string{}))
+	assert.False(t, IsPullable(nil))
+}
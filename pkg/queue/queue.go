@@ -5,6 +5,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
@@ -51,34 +52,86 @@ func remove(
 	}
 }
 
-// CreateSecretsWorkQueue creates a secrets work queue for a given namespace
-func CreateSecretsWorkQueue(
-	core corev1.CoreV1Interface,
-	namespace string,
-) (workqueue.RateLimitingInterface, cache.Indexer, cache.Controller) {
-	// create the pod watcher
-	// We must grab everything because we can't filter by labels or
-	// annotations
-	secretListWatcher := cache.NewListWatchFromClient(
-		core.RESTClient(),
-		"secrets",
-		namespace,
-		fields.Everything(),
-	)
-
+// CreateWorkQueue creates a work queue fed by an informer for each of
+// the given namespaces. Every namespace is watched by a single informer
+// if no namespaces are given. The indexers are keyed by the namespace
+// they watch.
+func CreateWorkQueue(
+	listWatch func(namespace string) cache.ListerWatcher,
+	objType runtime.Object,
+	namespaces []string,
+) (workqueue.RateLimitingInterface, map[string]cache.Indexer, []cache.Controller) {
 	// create the workqueue
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
 
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+
+	indexers := map[string]cache.Indexer{}
+	controllers := []cache.Controller{}
+	for _, namespace := range namespaces {
+		if _, ok := indexers[namespace]; ok {
+			continue
+		}
+		indexer, informer := CreateInformer(listWatch(namespace), objType, queue)
+		indexers[namespace] = indexer
+		controllers = append(controllers, informer)
+	}
+	return queue, indexers, controllers
+}
+
+// CreateInformer creates an informer for the objects listed and watched
+// by the list watcher, which adds the key of every changed object to the
+// queue
+func CreateInformer(
+	listWatch cache.ListerWatcher,
+	objType runtime.Object,
+	queue RateLimiter,
+) (cache.Indexer, cache.Controller) {
 	// Bind the workqueue to a cache with the help of an informer. This way we make sure that
-	// whenever the cache is updated, the secret key is added to the workqueue.
+	// whenever the cache is updated, the object key is added to the workqueue.
 	// Note that when we finally process the item from the workqueue, we might see a newer version
-	// of the Pod than the version which was responsible for triggering the update.
-	indexer, informer := cache.NewIndexerInformer(secretListWatcher, &v1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+	// of the object than the version which was responsible for triggering the update.
+	// We must grab everything because we can't filter by labels or
+	// annotations
+	return cache.NewIndexerInformer(listWatch, objType, 0, cache.ResourceEventHandlerFuncs{
 		AddFunc:    add(queue),
 		UpdateFunc: update(queue),
 		DeleteFunc: remove(queue),
 	}, cache.Indexers{})
-	return queue, indexer, informer
+}
+
+// CreateNamespaceInformer creates an informer which calls the handler
+// on changes to namespaces in the cluster
+func CreateNamespaceInformer(
+	core corev1.CoreV1Interface,
+	handler cache.ResourceEventHandler,
+) (cache.Indexer, cache.Controller) {
+	namespaceListWatcher := cache.NewListWatchFromClient(
+		core.RESTClient(),
+		"namespaces",
+		v1.NamespaceAll,
+		fields.Everything(),
+	)
+
+	return cache.NewIndexerInformer(
+		namespaceListWatcher, &v1.Namespace{}, 0, handler, cache.Indexers{})
+}
+
+// CreateTransformingInformer creates an informer for the objects listed
+// and watched by the list watcher, which calls the handler on changes to
+// them. Objects are transformed before they are cached, e.g. to drop data
+// which is never read.
+func CreateTransformingInformer(
+	listWatch cache.ListerWatcher,
+	objType runtime.Object,
+	handler cache.ResourceEventHandler,
+	indexers cache.Indexers,
+	transform cache.TransformFunc,
+) (cache.Indexer, cache.Controller) {
+	return cache.NewTransformingIndexerInformer(
+		listWatch, objType, 0, handler, indexers, transform)
 }
 
 // ParseWorkQueueKey parses a key from the workqueue into its namespace
@@ -7,9 +7,11 @@ import (
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/havulv/reflector/pkg/mocks"
 )
@@ -118,7 +120,14 @@ func TestRemove(t *testing.T) {
 	}
 }
 
-func TestCreateSecretsWorkQueue(t *testing.T) {
+func secretsListWatch(client *fake.Clientset) func(string) cache.ListerWatcher {
+	return func(namespace string) cache.ListerWatcher {
+		return cache.NewListWatchFromClient(
+			client.CoreV1().RESTClient(), "secrets", namespace, fields.Everything())
+	}
+}
+
+func TestCreateWorkQueue(t *testing.T) {
 	t.Parallel()
 	// this doesn't work because I don't understand how to add
 	// to the informer queue
@@ -138,8 +147,59 @@ func TestCreateSecretsWorkQueue(t *testing.T) {
 		close(watcherStarted)
 		return true, watch, nil
 	})
-	queue, indexer, informer := CreateSecretsWorkQueue(client.CoreV1(), "kube-system")
+	queue, indexers, informers := CreateWorkQueue(
+		secretsListWatch(client), &v1.Secret{}, []string{"kube-system"})
 	require.NotNil(t, queue)
+	require.Len(t, indexers, 1)
+	require.NotNil(t, indexers["kube-system"])
+	require.Len(t, informers, 1)
+}
+
+func TestCreateWorkQueueNamespaces(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		namespaces []string
+		indexers   []string
+	}{
+		{
+			"watches every namespace when none are given",
+			[]string{},
+			[]string{v1.NamespaceAll},
+		},
+		{
+			"watches each namespace given",
+			[]string{"hub-a", "hub-b", "hub-c"},
+			[]string{"hub-a", "hub-b", "hub-c"},
+		},
+		{
+			"watches duplicated namespaces once",
+			[]string{"hub-a", "hub-a"},
+			[]string{"hub-a"},
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			client := fake.NewSimpleClientset()
+			queue, indexers, informers := CreateWorkQueue(
+				secretsListWatch(client), &v1.Secret{}, test.namespaces)
+			require.NotNil(t, queue)
+			assert.Len(t, informers, len(test.indexers))
+			for _, ns := range test.indexers {
+				assert.NotNil(t, indexers[ns])
+			}
+			assert.Len(t, indexers, len(test.indexers))
+		})
+	}
+}
+
+func TestCreateNamespaceInformer(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	indexer, informer := CreateNamespaceInformer(
+		client.CoreV1(), cache.ResourceEventHandlerFuncs{})
 	require.NotNil(t, indexer)
 	require.NotNil(t, informer)
 }
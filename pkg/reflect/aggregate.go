@@ -0,0 +1,280 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// ErrorInvalidDockerConfig is used when the docker config of an aggregate
+// member can't be merged
+var ErrorInvalidDockerConfig = errors.New("invalid docker config")
+
+// aggregateOf parses the aggregate which the object is a member of. Only
+// secrets which are reflected and whose type is permitted are aggregated.
+func (r *reflector) aggregateOf(obj object) (string, error) {
+	if _, ok := obj.(*v1.Secret); !ok ||
+		!annotations.ShouldReflect(obj.GetAnnotations()) ||
+		!r.secretTypes.permits(obj) {
+		return "", nil
+	}
+	return annotations.AggregateTo(obj.GetAnnotations())
+}
+
+// updateAggregates records the aggregate of the object and merges both the
+// aggregate it is a member of and the one it left, if any, again
+func (r *reflector) updateAggregates(
+	ctx context.Context,
+	logger zerolog.Logger,
+	key string,
+	aggregate string,
+) error {
+	previous := r.aggregates.swap(key, aggregate)
+	if previous != "" && previous != aggregate {
+		if err := r.reflectAggregate(ctx, logger, previous); err != nil {
+			return err
+		}
+	}
+	if aggregate == "" {
+		return nil
+	}
+	return r.reflectAggregate(ctx, logger, aggregate)
+}
+
+// reflectAggregate merges the members of the aggregate which target each
+// namespace and reflects the result to it. Aggregates are deleted from the
+// namespaces which none of the members target anymore, if pruning or cascade
+// deletion is enabled, as the members may have left or been deleted.
+func (r *reflector) reflectAggregate(
+	ctx context.Context,
+	logger zerolog.Logger,
+	name string,
+) error {
+	logger = logger.With().Str("aggregate", name).Logger()
+
+	members, err := r.aggregateMembers(name)
+	if err != nil {
+		return err
+	}
+	byNamespace := map[string][]object{}
+	for _, member := range members {
+		namespaces, err := annotations.ParseOrFetchNamespaces(
+			ctx, r.core, member.GetAnnotations(), r.excludeNamespaces)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse namespaces of %s/%s", member.GetNamespace(), member.GetName())
+		}
+		namespaces = filterConsenting(logger, r.nsLister, r.requireConsent, r.kind, member, namespaces)
+		namespaces = filterTerminating(logger, r.nsLister, r.kind, member, namespaces)
+		for _, ns := range namespaces {
+			byNamespace[ns] = append(byNamespace[ns], member)
+		}
+	}
+
+	aggregated := map[string]object{}
+	hashes := map[string]string{}
+	namespaces := []string{}
+	for ns, nsMembers := range byNamespace {
+		aggregate, err := mergeSecrets(name, nsMembers)
+		if err != nil {
+			return errors.Wrapf(err, "unable to merge aggregate %s", name)
+		}
+		hash, err := hashObject(aggregate)
+		if err != nil {
+			return errors.Wrapf(err, "unable to hash aggregate %s", name)
+		}
+		aggregated[ns] = aggregate
+		hashes[ns] = hash
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	if err := batchOverNamespaces(
+		r.reflectConcurrency,
+		namespaces,
+		func(wg *sync.WaitGroup, ns string, errChan chan error) {
+			reflectObject(
+				ctx, logger.With().Str("reflectionNamespace", ns).Logger(),
+				wg, r.kind, r.recorder, aggregated[ns], name, hashes[ns], nil, nil, r.recreateImmutable, ns, errChan)
+		}); err != nil || (!r.prune && !r.cascadeDelete) {
+		return err
+	}
+
+	// aggregates don't originate from a namespace
+	existing, err := findReflections(ctx, r.kind, name, "")
+	if err != nil {
+		return errors.Wrapf(err, "unable to find namespaces aggregate %s was reflected to", name)
+	}
+	stale := reflections{}
+	for ns, names := range existing {
+		if _, ok := aggregated[ns]; !ok {
+			stale[ns] = names
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	logger.Info().
+		Strs("namespaces", stale.namespaces()).
+		Msg("deleting aggregate from namespaces that no member targets")
+	return cascadeDelete(ctx, logger, r.kind, stale, r.reflectConcurrency)
+}
+
+// aggregateMembers finds the watched members of the aggregate, ordered by
+// their keys, with the data keys filtered and renamed by their annotations
+func (r *reflector) aggregateMembers(name string) ([]object, error) {
+	keys := []string{}
+	byKey := map[string]object{}
+	for _, cached := range r.indexers.list() {
+		obj, ok := cached.(object)
+		if !ok {
+			continue
+		}
+		aggregate, err := r.aggregateOf(obj)
+		if err != nil || aggregate != name {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+
+		// the cached object must not be changed
+		member, ok := obj.DeepCopyObject().(object)
+		if !ok {
+			return nil, errors.Errorf("could not copy %s", r.kind.Name())
+		}
+		filterKeys(member, member.GetAnnotations())
+		if err := mapKeys(member, member.GetAnnotations()); err != nil {
+			return nil, errors.Wrapf(err, "unable to map keys of %s", key)
+		}
+		keys = append(keys, key)
+		byKey[key] = member
+	}
+	sort.Strings(keys)
+
+	members := []object{}
+	for _, key := range keys {
+		members = append(members, byKey[key])
+	}
+	return members, nil
+}
+
+// mergeSecrets merges the data of the secrets, in order, into the aggregate.
+// The aggregate is a docker config secret if every member is one, and an
+// opaque secret otherwise.
+func mergeSecrets(name string, members []object) (object, error) {
+	aggregate := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{},
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{},
+	}
+
+	keys := []string{}
+	for _, member := range members {
+		secret, ok := member.(*v1.Secret)
+		if !ok {
+			continue
+		}
+		keys = append(keys, secret.Namespace+"/"+secret.Name)
+		if secret.Type != v1.SecretTypeDockerConfigJson {
+			aggregate.Type = v1.SecretTypeOpaque
+		}
+		for key, value := range secret.Data {
+			existing, ok := aggregate.Data[key]
+			if !ok {
+				aggregate.Data[key] = value
+				continue
+			}
+			merged, err := mergeValues(key, existing, value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to merge %q of %s/%s", key, secret.Namespace, secret.Name)
+			}
+			aggregate.Data[key] = merged
+		}
+	}
+	aggregate.Annotations[annotations.AggregatedFromAnnotation] = strings.Join(keys, ",")
+	return aggregate, nil
+}
+
+// mergeValues merges a value of a later member into the value of the
+// aggregate. Docker configs are merged by registry and PEM bundles by
+// block, any other value of a later member replaces the existing value.
+func mergeValues(key string, existing []byte, value []byte) ([]byte, error) {
+	if key == v1.DockerConfigJsonKey {
+		return mergeDockerConfigs(existing, value)
+	}
+	if isPEM(existing) && isPEM(value) {
+		return mergePEM(existing, value), nil
+	}
+	return value, nil
+}
+
+// dockerConfig is the part of a `.dockerconfigjson` which is merged
+type dockerConfig struct {
+	Auths map[string]json.RawMessage `json:"auths"`
+}
+
+// mergeDockerConfigs merges the registries of both docker configs, where
+// the later config wins for registries that are in both
+func mergeDockerConfigs(existing []byte, value []byte) ([]byte, error) {
+	merged := dockerConfig{Auths: map[string]json.RawMessage{}}
+	for _, raw := range [][]byte{existing, value} {
+		config := dockerConfig{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, errors.Wrap(ErrorInvalidDockerConfig, err.Error())
+		}
+		for registry, auth := range config.Auths {
+			merged.Auths[registry] = auth
+		}
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal docker config")
+	}
+	return raw, nil
+}
+
+// isPEM checks if the value starts with a PEM block
+func isPEM(value []byte) bool {
+	block, _ := pem.Decode(value)
+	return block != nil
+}
+
+// mergePEM appends the PEM blocks of the value which the existing
+// bundle doesn't hold yet
+func mergePEM(existing []byte, value []byte) []byte {
+	merged := &bytes.Buffer{}
+	seen := map[string]struct{}{}
+	for _, bundle := range [][]byte{existing, value} {
+		rest := bundle
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			encoded := pem.EncodeToMemory(block)
+			if _, ok := seen[string(encoded)]; ok {
+				continue
+			}
+			seen[string(encoded)] = struct{}{}
+			merged.Write(encoded)
+		}
+	}
+	return merged.Bytes()
+}
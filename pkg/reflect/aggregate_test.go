@@ -0,0 +1,180 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func certificate(content string) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(content)})
+}
+
+func aggregateMember(namespace string, secretType v1.SecretType, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: namespace,
+			Annotations: map[string]string{
+				annotations.ReflectAnnotation:     "true",
+				annotations.NamespaceAnnotation:   "apps",
+				annotations.AggregateToAnnotation: "registry-creds",
+			},
+		},
+		Type: secretType,
+		Data: data,
+	}
+}
+
+func TestMergeSecrets(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		members    []object
+		secretType v1.SecretType
+		data       map[string][]byte
+		err        bool
+	}{
+		{
+			"merges docker configs by registry",
+			[]object{
+				aggregateMember("team-a", v1.SecretTypeDockerConfigJson, map[string][]byte{
+					v1.DockerConfigJsonKey: []byte(`{"auths":{"a.io":{"auth":"a"},"shared.io":{"auth":"a"}}}`),
+				}),
+				aggregateMember("team-b", v1.SecretTypeDockerConfigJson, map[string][]byte{
+					v1.DockerConfigJsonKey: []byte(`{"auths":{"b.io":{"auth":"b"},"shared.io":{"auth":"b"}}}`),
+				}),
+			},
+			v1.SecretTypeDockerConfigJson,
+			map[string][]byte{
+				v1.DockerConfigJsonKey: []byte(`{"auths":{"a.io":{"auth":"a"},"b.io":{"auth":"b"},"shared.io":{"auth":"b"}}}`),
+			},
+			false,
+		},
+		{
+			"bundles PEM blocks without duplicates",
+			[]object{
+				aggregateMember("team-a", v1.SecretTypeOpaque, map[string][]byte{
+					"ca.crt": append(certificate("a"), certificate("shared")...),
+				}),
+				aggregateMember("team-b", v1.SecretTypeTLS, map[string][]byte{
+					"ca.crt": append(certificate("shared"), certificate("b")...),
+				}),
+			},
+			v1.SecretTypeOpaque,
+			map[string][]byte{
+				"ca.crt": bytes.Join([][]byte{certificate("a"), certificate("shared"), certificate("b")}, nil),
+			},
+			false,
+		},
+		{
+			"later members win for other values",
+			[]object{
+				aggregateMember("team-a", v1.SecretTypeOpaque, map[string][]byte{"a": []byte("a"), "shared": []byte("a")}),
+				aggregateMember("team-b", v1.SecretTypeOpaque, map[string][]byte{"b": []byte("b"), "shared": []byte("b")}),
+			},
+			v1.SecretTypeOpaque,
+			map[string][]byte{"a": []byte("a"), "b": []byte("b"), "shared": []byte("b")},
+			false,
+		},
+		{
+			"fails on invalid docker configs",
+			[]object{
+				aggregateMember("team-a", v1.SecretTypeDockerConfigJson, map[string][]byte{
+					v1.DockerConfigJsonKey: []byte(`{"auths":{}}`),
+				}),
+				aggregateMember("team-b", v1.SecretTypeDockerConfigJson, map[string][]byte{
+					v1.DockerConfigJsonKey: []byte(`not json`),
+				}),
+			},
+			"",
+			nil,
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			merged, err := mergeSecrets("registry-creds", test.members)
+			if test.err {
+				assert.ErrorIs(t, err, ErrorInvalidDockerConfig)
+				return
+			}
+			require.Nil(t, err)
+			secret, ok := merged.(*v1.Secret)
+			require.True(t, ok)
+			assert.Equal(t, "registry-creds", secret.Name)
+			assert.Equal(t, test.secretType, secret.Type)
+			assert.Equal(t, test.data, secret.Data)
+			assert.Equal(t, "team-a/creds,team-b/creds", secret.Annotations[annotations.AggregatedFromAnnotation])
+		})
+	}
+}
+
+func TestProcessAggregate(t *testing.T) {
+	ctx := context.Background()
+	teamA := aggregateMember("team-a", v1.SecretTypeOpaque, map[string][]byte{"a": []byte("a")})
+	teamB := aggregateMember("team-b", v1.SecretTypeOpaque, map[string][]byte{"b": []byte("b")})
+	store := storeOf(t, teamA, teamB)
+	client := fake.NewSimpleClientset()
+
+	r := reflector{
+		ctx:                ctx,
+		logger:             zerolog.Nop(),
+		core:               client.CoreV1(),
+		kind:               secretsClient(client),
+		indexers:           objectStores{v1.NamespaceAll: store},
+		nsLister:           namespaceLister(t),
+		reflectConcurrency: 1,
+	}
+	getAggregate := func() (*v1.Secret, error) {
+		return client.CoreV1().Secrets("apps").Get(ctx, "registry-creds", metav1.GetOptions{})
+	}
+
+	require.Nil(t, r.process("team-a/creds"))
+	require.Nil(t, r.process("team-b/creds"))
+	aggregate, err := getAggregate()
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("a"), "b": []byte("b")}, aggregate.Data)
+	assert.Equal(t, "team-a/creds,team-b/creds", aggregate.Annotations[annotations.AggregatedFromAnnotation])
+	assert.Equal(t, "registry-creds", aggregate.Annotations[annotations.ReflectedNameAnnotation])
+	// members aren't reflected on their own
+	_, err = client.CoreV1().Secrets("apps").Get(ctx, "creds", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// a member that leaves the aggregate is merged out of it
+	left := teamB.DeepCopy()
+	delete(left.Annotations, annotations.AggregateToAnnotation)
+	left.Annotations[annotations.NamespaceAnnotation] = "other"
+	require.Nil(t, store.Update(left))
+	require.Nil(t, r.process("team-b/creds"))
+	aggregate, err = getAggregate()
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("a")}, aggregate.Data)
+
+	// the aggregate is left as it is without pruning or cascade deletion
+	require.Nil(t, store.Delete(teamA))
+	require.Nil(t, r.process("team-a/creds"))
+	_, err = getAggregate()
+	require.Nil(t, err)
+
+	// and deleted once no member targets the namespace otherwise
+	require.Nil(t, store.Add(teamA))
+	require.Nil(t, r.process("team-a/creds"))
+	r.cascadeDelete = true
+	require.Nil(t, store.Delete(teamA))
+	require.Nil(t, r.process("team-a/creds"))
+	_, err = getAggregate()
+	assert.True(t, apierrors.IsNotFound(err))
+}
@@ -0,0 +1,80 @@
+package reflect
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// ErrorCollision is used when a reflection would replace an object in the
+// namespace which is not a reflection of the same object
+var ErrorCollision = errors.New("reflection collides with another object")
+
+// reasonCollision is the reason of the events recorded for collisions
+const reasonCollision = "ReflectionCollision"
+
+// isReflectionOf checks if the existing object is a reflection of the object.
+// A config map projected from a secret is only a reflection of the secret's
+// projection, not of a config map with the same name as the secret.
+func isReflectionOf(existing object, obj object) bool {
+	existingAnnotations := existing.GetAnnotations()
+	return annotations.IsReflectionOf(
+		existingAnnotations, existing.GetName(), obj.GetNamespace(), obj.GetName()) &&
+		annotations.IsProjection(existingAnnotations) == annotations.IsProjection(obj.GetAnnotations())
+}
+
+// collision creates the error for an object which collides with an existing
+// object, naming the object that the existing object is a reflection of
+func collision(client kind, existing object) error {
+	existingAnnotations := existing.GetAnnotations()
+	if _, ok := existingAnnotations[annotations.ReflectionHashAnnotation]; !ok {
+		return errors.Wrapf(ErrorCollision, "%s %s/%s is not a reflection",
+			client.Name(), existing.GetNamespace(), existing.GetName())
+	}
+
+	origin := existingAnnotations[annotations.ReflectedFromAnnotation] + "/" + existing.GetName()
+	if name, ok := existingAnnotations[annotations.ReflectedNameAnnotation]; ok {
+		origin = existingAnnotations[annotations.ReflectedFromAnnotation] + "/" + name
+	}
+	if uid, ok := existingAnnotations[annotations.ReflectedUIDAnnotation]; ok {
+		origin += " (" + uid + ")"
+	}
+	return errors.Wrapf(ErrorCollision, "%s %s/%s is a reflection of %s",
+		client.Name(), existing.GetNamespace(), existing.GetName(), origin)
+}
+
+// outranks checks if the object may replace the existing reflection of
+// another object, which it does if it has a higher priority. Only objects
+// which the reflector owns are ever replaced.
+func outranks(obj object, existing object) bool {
+	existingAnnotations := existing.GetAnnotations()
+	if _, ok := existingAnnotations[annotations.ReflectionHashAnnotation]; !ok ||
+		!annotations.CanOperate(existingAnnotations) {
+		return false
+	}
+	// the priority of the object is validated before it is reflected
+	priority, _ := annotations.Priority(obj.GetAnnotations())
+	// reflections with an invalid priority are outranked like those without one
+	existingPriority, _ := annotations.Priority(existingAnnotations)
+	return priority > existingPriority
+}
+
+// recordCollision counts a namespace that the object could not be
+// reflected to because of a collision, and records an event for it on
+// the object. There are no events for aggregates, as they aren't objects.
+func recordCollision(
+	recorder record.EventRecorder,
+	client kind,
+	obj object,
+	ns string,
+	err error,
+) {
+	reflectorCollisions.WithLabelValues(client.Name(), obj.GetName(), ns).Inc()
+	if recorder == nil || obj.GetNamespace() == "" {
+		return
+	}
+	recorder.Eventf(obj, v1.EventTypeWarning, reasonCollision,
+		"Unable to reflect to namespace %s: %s", ns, err)
+}
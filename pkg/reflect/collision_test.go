@@ -0,0 +1,241 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestOutranks(t *testing.T) {
+	reflection := func(ann map[string]string) object {
+		objAnnotations := map[string]string{
+			annotations.ReflectionHashAnnotation:  "hash",
+			annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+		}
+		for key, value := range ann {
+			objAnnotations[key] = value
+		}
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: objAnnotations}}
+	}
+	prioritized := func(priority string) object {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{annotations.PriorityAnnotation: priority},
+		}}
+	}
+
+	tests := []struct {
+		descrip  string
+		obj      object
+		existing object
+		outranks bool
+	}{
+		{
+			"a higher priority outranks",
+			prioritized("10"),
+			reflection(map[string]string{annotations.PriorityAnnotation: "5"}),
+			true,
+		},
+		{
+			"an equal priority does not outrank",
+			prioritized("5"),
+			reflection(map[string]string{annotations.PriorityAnnotation: "5"}),
+			false,
+		},
+		{
+			"reflections without a priority have a priority of zero",
+			prioritized("1"),
+			reflection(nil),
+			true,
+		},
+		{
+			"reflections with an invalid priority have a priority of zero",
+			prioritized("-1"),
+			reflection(map[string]string{annotations.PriorityAnnotation: "high"}),
+			false,
+		},
+		{
+			"objects which aren't reflections are never outranked",
+			prioritized("10"),
+			&v1.Secret{},
+			false,
+		},
+		{
+			"reflections which are owned by someone else are never outranked",
+			prioritized("10"),
+			reflection(map[string]string{annotations.ReflectionOwnerAnnotation: "me"}),
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.outranks, outranks(test.obj, test.existing))
+		})
+	}
+}
+
+func TestReflectCollision(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	recorder := record.NewFakeRecorder(10)
+	reflectSource := func(namespace string, priority string) {
+		require.Nil(t, reflectToNamespaces(
+			ctx,
+			zerolog.Nop(),
+			secretsClient(client),
+			recorder,
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "collider",
+					Namespace:   namespace,
+					UID:         types.UID(namespace + "-uid"),
+					Annotations: map[string]string{annotations.PriorityAnnotation: priority},
+				},
+				Data: map[string][]byte{"from": []byte(namespace)},
+			},
+			[]string{"target"},
+			nil,
+			namespaceLister(t),
+			false,
+			false,
+			1))
+	}
+	get := func() *v1.Secret {
+		sec, err := client.CoreV1().Secrets("target").Get(ctx, "collider", metav1.GetOptions{})
+		require.Nil(t, err)
+		return sec
+	}
+	collisions := func() float64 {
+		m, err := reflectorCollisions.GetMetricWithLabelValues("secret", "collider", "target")
+		require.Nil(t, err)
+		metric := &dto.Metric{}
+		require.Nil(t, m.Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+
+	reflectSource("team-a", "")
+	reflected := get()
+	assert.Equal(t, "team-a", reflected.Annotations[annotations.ReflectedFromAnnotation])
+	assert.Equal(t, "team-a-uid", reflected.Annotations[annotations.ReflectedUIDAnnotation])
+
+	// the first reflection wins if neither has a priority
+	reflectSource("team-b", "")
+	assert.Equal(t, []byte("team-a"), get().Data["from"])
+	assert.Equal(t, 1.0, collisions())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning ReflectionCollision Unable to reflect to namespace target")
+
+	// a higher priority replaces the reflection of the other object
+	reflectSource("team-b", "10")
+	reflected = get()
+	assert.Equal(t, []byte("team-b"), reflected.Data["from"])
+	assert.Equal(t, "team-b", reflected.Annotations[annotations.ReflectedFromAnnotation])
+	assert.Equal(t, "10", reflected.Annotations[annotations.PriorityAnnotation])
+
+	// which the lower priority can't take back
+	reflectSource("team-a", "")
+	assert.Equal(t, []byte("team-b"), get().Data["from"])
+	assert.Equal(t, 2.0, collisions())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "is a reflection of team-b/collider (team-b-uid)")
+}
+
+func TestReflectRecreatedObject(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	recorder := record.NewFakeRecorder(10)
+	reflectSource := func(uid string, data string) {
+		require.Nil(t, reflectToNamespaces(
+			ctx,
+			zerolog.Nop(),
+			secretsClient(client),
+			recorder,
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "recreated",
+					Namespace: "source",
+					UID:       types.UID(uid),
+				},
+				Data: map[string][]byte{"data": []byte(data)},
+			},
+			[]string{"target"},
+			nil,
+			namespaceLister(t),
+			false,
+			false,
+			1))
+	}
+
+	reflectSource("first-uid", "first")
+	// the reflected UID is ignored, so an object which is deleted and
+	// created again keeps its reflections
+	reflectSource("second-uid", "second")
+	reflected, err := client.CoreV1().Secrets("target").Get(ctx, "recreated", metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, []byte("second"), reflected.Data["data"])
+	assert.Equal(t, "second-uid", reflected.Annotations[annotations.ReflectedUIDAnnotation])
+	assert.Empty(t, recorder.Events)
+}
+
+func TestProcessWildcardSkipsOwnNamespace(t *testing.T) {
+	ctx := context.Background()
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "wildcard",
+			Namespace: "source",
+			Annotations: map[string]string{
+				annotations.ReflectAnnotation:   "true",
+				annotations.NamespaceAnnotation: "*",
+			},
+		},
+		Data: map[string][]byte{"key": []byte("value")},
+	}
+	client := fake.NewSimpleClientset(
+		source.DeepCopy(),
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "source"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "target"}},
+	)
+	recorder := record.NewFakeRecorder(10)
+	r := reflector{
+		ctx:                ctx,
+		logger:             zerolog.Nop(),
+		core:               client.CoreV1(),
+		kind:               secretsClient(client),
+		recorder:           recorder,
+		indexers:           objectStores{v1.NamespaceAll: storeOf(t, source)},
+		nsLister:           namespaceLister(t),
+		reflectConcurrency: 1,
+	}
+	collisions := func() float64 {
+		metric := &dto.Metric{}
+		require.Nil(t, reflectorCollisions.WithLabelValues("secret", "wildcard", "source").Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+
+	// the object is reflected everywhere but onto itself
+	client.ClearActions()
+	require.Nil(t, r.process("source/wildcard"))
+	_, err := client.CoreV1().Secrets("target").Get(ctx, "wildcard", metav1.GetOptions{})
+	require.Nil(t, err)
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "source", action.GetNamespace(), action.GetVerb())
+	}
+	assert.Equal(t, 0.0, collisions())
+	assert.Empty(t, recorder.Events)
+
+	// unless the reflection in its own namespace is named differently
+	assert.Equal(t, []string{"source", "target"}, withoutObject(
+		source, annotations.TargetNames{"source": "wildcard-copy"}, []string{"source", "target"}))
+}
@@ -0,0 +1,53 @@
+package reflect
+
+import (
+	"github.com/rs/zerolog"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// filterConsenting removes the namespaces which don't consent to the
+// object being reflected into them. Rejections are logged and counted
+// rather than failing the reflection to the other namespaces.
+func filterConsenting(
+	logger zerolog.Logger,
+	nsLister corelisters.NamespaceLister,
+	requireConsent bool,
+	client kind,
+	obj object,
+	namespaces []string,
+) []string {
+	consenting := []string{}
+	for _, ns := range namespaces {
+		// namespaces that aren't cached yet are treated as having no annotations
+		nsAnnotations := map[string]string{}
+		if found, err := nsLister.Get(ns); err == nil {
+			nsAnnotations = found.Annotations
+		}
+
+		// pulling a secret implies consenting to it
+		if annotations.PullsSecret(nsAnnotations, obj.GetNamespace(), obj.GetName()) {
+			consenting = append(consenting, ns)
+			continue
+		}
+
+		accepts, err := annotations.AcceptsSecret(
+			nsAnnotations, obj.GetNamespace(), obj.GetName(), requireConsent)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("reflectionNamespace", ns).
+				Msgf("unable to parse namespace consent, rejecting %s", client.Name())
+		}
+		if !accepts {
+			logger.Info().
+				Str("reflectionNamespace", ns).
+				Msgf("namespace does not accept %s, skipping", client.Name())
+			reflectorRejections.WithLabelValues(client.Name(), obj.GetName(), ns).Inc()
+			continue
+		}
+		consenting = append(consenting, ns)
+	}
+	return consenting
+}
@@ -0,0 +1,131 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func namespaceLister(t *testing.T, namespaces ...*v1.Namespace) corelisters.NamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		require.Nil(t, indexer.Add(ns))
+	}
+	return corelisters.NewNamespaceLister(indexer)
+}
+
+func consentNamespace(name string, acceptFrom string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				annotations.AcceptFromAnnotation: acceptFrom,
+			},
+		},
+	}
+}
+
+func TestFilterConsenting(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		required   bool
+		consenting []string
+	}{
+		{
+			"namespaces without the annotation accept when consent is not required",
+			false,
+			[]string{"accepts", "accepts-glob", "pulls", "no-annotation", "not-cached"},
+		},
+		{
+			"namespaces without the annotation reject when consent is required",
+			true,
+			[]string{"accepts", "accepts-glob", "pulls"},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			lister := namespaceLister(t,
+				consentNamespace("accepts", "consent-source/consent-secret"),
+				consentNamespace("accepts-glob", "consent-source/*"),
+				consentNamespace("rejects", "other/*"),
+				consentNamespace("invalid", "consent-source/[bad"),
+				&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "no-annotation"}},
+				&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name: "pulls",
+					Annotations: map[string]string{
+						annotations.PullAnnotation: "consent-source/consent-secret",
+					},
+				}},
+			)
+			sec := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "consent-secret",
+					Namespace: "consent-source",
+				},
+			}
+
+			consenting := filterConsenting(
+				zerolog.New(bytes.NewBuffer([]byte{})),
+				lister,
+				test.required,
+				secretsClient(fake.NewSimpleClientset()),
+				sec,
+				[]string{"accepts", "accepts-glob", "rejects", "invalid", "pulls", "no-annotation", "not-cached"})
+			assert.Equal(t, test.consenting, consenting)
+
+			m, err := reflectorRejections.GetMetricWithLabelValues("secret", "consent-secret", "rejects")
+			require.Nil(t, err)
+			metric := &dto.Metric{}
+			require.Nil(t, m.Write(metric))
+			assert.GreaterOrEqual(t, metric.Counter.GetValue(), float64(1))
+		})
+	}
+}
+
+func TestReflectToNamespacesConsent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset()
+	err := reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "secret",
+				Namespace:   "source",
+				Annotations: map[string]string{},
+			},
+		},
+		[]string{"accepts", "rejects"},
+		nil,
+		namespaceLister(t,
+			consentNamespace("accepts", "source/secret"),
+			consentNamespace("rejects", "source/other-secret")),
+		false,
+		false,
+		1)
+	require.Nil(t, err)
+
+	_, err = client.CoreV1().Secrets("accepts").Get(ctx, "secret", metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = client.CoreV1().Secrets("rejects").Get(ctx, "secret", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
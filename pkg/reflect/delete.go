@@ -2,97 +2,189 @@ package reflect
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"github.com/havulv/reflector/pkg/annotations"
 )
 
+// reflections are the names of the existing reflections of an object,
+// by the namespace they are in
+type reflections map[string][]string
+
+// namespaces are the namespaces holding reflections, in a stable order
+func (r reflections) namespaces() []string {
+	namespaces := make([]string, 0, len(r))
+	for ns := range r {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
 func cascadeDelete(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretsGetter,
-	secret string,
-	namespaces []string,
+	client kind,
+	existing reflections,
 	concurrency int,
 ) error {
 	// shortcircuit if we have the best case of `do nothing`
-	if len(namespaces) == 0 {
+	if len(existing) == 0 {
 		logger.Info().Msg("no namespaces, skipping")
 		return nil
 	}
 
 	return batchOverNamespaces(
 		concurrency,
-		namespaces,
-		deleteLambda(ctx, logger, client, secret))
+		existing.namespaces(),
+		deleteLambda(ctx, logger, client, existing))
 }
 
 func deleteLambda(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretsGetter,
-	secret string,
+	client kind,
+	existing reflections,
 ) func(wg *sync.WaitGroup, ns string, errChan chan error) {
 	return func(wg *sync.WaitGroup, ns string, errChan chan error) {
 		// spin off a goroutine for every level of concurrency
-		deleteSecret(
+		deleteObject(
 			ctx, logger.With().
 				Str("reflectionNamespace", ns).Logger(),
-			wg, client, secret, ns, errChan)
+			wg, client, existing[ns], ns, errChan)
 	}
 }
 
-func deleteSecret(
+func deleteObject(
 	ctx context.Context,
 	logger zerolog.Logger,
 	wg *sync.WaitGroup,
-	client corev1.SecretsGetter,
-	secret string,
+	client kind,
+	names []string,
 	ns string,
 	errChan chan error,
 ) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		secretClient := client.Secrets(ns)
-		if err := secretClient.Delete(
-			ctx, secret, metav1.DeleteOptions{},
-		); err != nil && !apierrors.IsNotFound(err) {
-			logger.Error().Err(err).Msg("unable to delete secret")
-			errChan <- errors.Wrap(
-				err,
-				"error while removing secret from the namspace")
+		// the reflections in a namespace are deleted one after the other,
+		// so that there is at most one error for each namespace
+		for _, name := range names {
+			if err := deleteReflection(ctx, logger, client, name, ns); err != nil {
+				errChan <- err
+				return
+			}
 		}
 	}()
 }
 
-func findExistingSecretNamespaces(
+// deleteReflection deletes a reflection and detaches it from the
+// service accounts in its namespace
+func deleteReflection(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	name string,
+	ns string,
+) error {
+	labels := []string{"delete", client.Name(), name, "true", ns}
+	err := client.Objects(ns).Delete(ctx, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		labels[3] = "false"
+	}
+	reflectorReflections.WithLabelValues(labels...).Inc()
+
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Error().Err(err).Msgf("unable to delete %s", client.Name())
+		return errors.Wrapf(
+			err,
+			"error while removing %s from the namspace", client.Name())
+	}
+
+	if err := detachFromServiceAccounts(ctx, logger, client, name, ns); err != nil {
+		logger.Error().Err(err).Msgf("unable to detach %s", client.Name())
+		return errors.Wrapf(
+			err,
+			"error while detaching %s from service accounts", client.Name())
+	}
+	return nil
+}
+
+// pruneReflections deletes the reflections of an object from the
+// namespaces that it was reflected to but which are no longer targeted,
+// along with reflections which no longer have their target name
+func pruneReflections(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	obj object,
+	namespaces []string,
+	targetNames annotations.TargetNames,
+	concurrency int,
+) error {
+	existing, err := findReflections(ctx, client, obj.GetName(), obj.GetNamespace())
+	if err != nil {
+		return errors.Wrapf(err, "unable to find namespaces %s was reflected to", client.Name())
+	}
+
+	targeted := map[string]struct{}{}
+	for _, ns := range namespaces {
+		targeted[ns] = struct{}{}
+	}
+	stale := reflections{}
+	for ns, names := range existing {
+		_, ok := targeted[ns]
+		for _, name := range names {
+			if !ok || name != targetNames.Name(ns, obj.GetName()) {
+				stale[ns] = append(stale[ns], name)
+			}
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+	logger.Info().
+		Strs("namespaces", stale.namespaces()).
+		Msg("pruning reflections from namespaces that are no longer targeted")
+	return cascadeDelete(ctx, logger, client, stale, concurrency)
+}
+
+// findReflections finds the reflections, owned by the reflector, of the
+// object in the source namespace. Reflections are listed by their reflection
+// of label and found by the object they originate from, as they may be named
+// differently than the object. Config maps projected from a secret are only
+// found by the projection kind.
+func findReflections(
 	ctx context.Context,
-	core corev1.CoreV1Interface,
+	client kind,
 	name string,
-) ([]string, error) {
-	// fetch all namespaces
-	allNs, err := core.Namespaces().List(ctx, metav1.ListOptions{})
+	sourceNamespace string,
+) (reflections, error) {
+	found, err := client.List(ctx, metav1.NamespaceAll, metav1.ListOptions{
+		LabelSelector: annotations.ReflectionOfSelector(sourceNamespace, name),
+	})
 	if err != nil {
-		return []string{}, errors.Wrap(err, "unable to list all namespaces")
+		return reflections{}, errors.Wrapf(err, "unable to list %ss in all namespaces", client.Name())
 	}
 
-	namespaces := []string{}
-	for _, item := range allNs.Items {
-		found, err := core.Secrets(item.Name).Get(ctx, name, metav1.GetOptions{})
-		if err != nil {
-			return []string{}, errors.Wrap(err, "could not fetch secret for ns")
+	existing := reflections{}
+	for _, item := range found {
+		if item.GetName() == name && item.GetNamespace() == sourceNamespace {
+			continue
 		}
-		if annotations.CanOperate(found.Annotations) {
-			namespaces = append(namespaces, item.Name)
+		if annotations.CanOperate(item.GetAnnotations()) &&
+			annotations.IsProjection(item.GetAnnotations()) == isProjectionKind(client) &&
+			annotations.IsReflectionOf(item.GetAnnotations(), item.GetName(), sourceNamespace, name) {
+			existing[item.GetNamespace()] = append(existing[item.GetNamespace()], item.GetName())
 		}
 	}
 
-	return namespaces, nil
+	return existing, nil
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -111,15 +112,18 @@ func TestCascadeDelete(t *testing.T) {
 			}
 			client := fake.NewSimpleClientset(objs...)
 
+			existing := reflections{}
+			for _, ns := range test.namespaces {
+				existing[ns] = []string{test.toDelete}
+			}
 			assert.Equal(
 				t,
 				test.err,
 				cascadeDelete(
 					ctx,
 					l,
-					client.CoreV1(),
-					test.toDelete,
-					test.namespaces,
+					secretsClient(client),
+					existing,
 					test.concurrency))
 			if test.err == nil && len(test.namespaces) > 0 {
 				sec, err := client.CoreV1().Secrets(test.namespaces[len(test.namespaces)-1]).Get(
@@ -131,7 +135,7 @@ func TestCascadeDelete(t *testing.T) {
 	}
 }
 
-func TestDeleteSecret(t *testing.T) {
+func TestDeleteObject(t *testing.T) {
 	tests := []struct {
 		d         string
 		err       error
@@ -179,7 +183,7 @@ func TestDeleteSecret(t *testing.T) {
 			errChan := make(chan error, 2)
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			deleteSecret(ctx, l, &wg, client.CoreV1(), s, "default", errChan)
+			deleteObject(ctx, l, &wg, secretsClient(client), []string{s}, "default", errChan)
 
 			wg.Wait()
 			select {
@@ -201,92 +205,105 @@ func TestDeleteSecret(t *testing.T) {
 	}
 }
 
-func TestFindExistingSecretNamespaces(t *testing.T) {
+func reflectionOf(namespace string, name string) map[string]string {
+	return map[string]string{annotations.ReflectionOfLabel: annotations.ReflectionOf(namespace, name)}
+}
+
+func TestFindReflections(t *testing.T) {
+	owned := func(ns string, from string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "thing",
+				Namespace: ns,
+				Labels:    reflectionOf(from, "thing"),
+				Annotations: map[string]string{
+					annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+					annotations.ReflectedFromAnnotation:   from,
+				},
+			},
+		}
+	}
+
+	renamed := func(ns string, name string) *v1.Secret {
+		sec := owned(ns, "source")
+		sec.Name = name
+		sec.Annotations[annotations.ReflectedNameAnnotation] = "thing"
+		return sec
+	}
+
 	tests := []struct {
-		descrip       string
-		name          string
-		namespaces    []string
-		listErr       error
-		secretErr     error
-		retSecrets    []*v1.Secret
-		retNamespaces *v1.NamespaceList
+		descrip     string
+		name        string
+		reflections reflections
+		listErr     error
+		retSecrets  []*v1.Secret
 	}{
 		{
 			"finds no namespaces to delete from",
 			"thing",
-			[]string{},
-			nil,
+			reflections{},
 			nil,
 			[]*v1.Secret{},
-			&v1.NamespaceList{
-				Items: []v1.Namespace{},
-			},
 		},
 		{
-			"returns errors when trying to list all namespaces",
+			"returns errors when trying to list secrets",
 			"thing",
-			[]string{},
+			reflections{},
 			errors.New("some Error"),
-			nil,
 			[]*v1.Secret{},
-			&v1.NamespaceList{
-				Items: []v1.Namespace{},
-			},
 		},
 		{
-			"returns errors when trying to fetch a secret",
+			"finds renamed reflections by their source",
 			"thing",
-			[]string{},
+			reflections{"ns1": {"thing", "db-creds"}, "ns2": {"legacy-db"}, "source": {"copy"}},
 			nil,
-			errors.New("some Error"),
-			[]*v1.Secret{},
-			&v1.NamespaceList{
-				Items: []v1.Namespace{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "ns1",
-						},
-					},
-				},
+			[]*v1.Secret{
+				owned("ns1", "source"),
+				renamed("ns1", "db-creds"),
+				renamed("ns2", "legacy-db"),
+				renamed("source", "copy"),
+				func() *v1.Secret {
+					sec := renamed("ns3", "thing")
+					sec.Annotations[annotations.ReflectedNameAnnotation] = "other-thing"
+					return sec
+				}(),
 			},
 		},
 		{
 			"returns a list of namespaces with secrets",
 			"thing",
-			[]string{"ns1"},
-			nil,
+			reflections{"ns1": {"thing"}, "ns4": {"thing"}},
 			nil,
 			[]*v1.Secret{
+				owned("ns1", "source"),
 				{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "thing",
-						Namespace: "ns1",
+						Namespace: "ns2",
+						Labels:    reflectionOf("source", "thing"),
 						Annotations: map[string]string{
-							annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+							annotations.ReflectionOwnerAnnotation: "other",
+							annotations.ReflectedFromAnnotation:   "source",
 						},
 					},
 				},
+				owned("ns3", "other-source"),
+				owned("ns4", "source"),
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      "thing",
-						Namespace: "ns2",
+						Name:      "other-thing",
+						Namespace: "ns5",
+						Labels:    reflectionOf("source", "thing"),
 						Annotations: map[string]string{
-							annotations.ReflectionOwnerAnnotation: "other",
+							annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+							annotations.ReflectedFromAnnotation:   "source",
 						},
 					},
 				},
-			},
-			&v1.NamespaceList{
-				Items: []v1.Namespace{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "ns1",
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "ns2",
-						},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "thing",
+						Namespace: "source",
 					},
 				},
 			},
@@ -301,16 +318,9 @@ func TestFindExistingSecretNamespaces(t *testing.T) {
 			defer cancel()
 
 			client := fake.NewSimpleClientset()
-			if len(test.retSecrets) > 0 {
-				tr := client.Tracker()
-				for _, s := range test.retSecrets {
-					assert.Nil(t, tr.Add(s))
-				}
-			}
-
-			if test.retNamespaces != nil {
-				tr := client.Tracker()
-				assert.Nil(t, tr.Add(test.retNamespaces))
+			tr := client.Tracker()
+			for _, s := range test.retSecrets {
+				assert.Nil(t, tr.Add(s))
 			}
 
 			if test.listErr != nil {
@@ -320,18 +330,151 @@ func TestFindExistingSecretNamespaces(t *testing.T) {
 					})
 			}
 
-			if test.secretErr != nil {
-				client.PrependReactor("get", "*",
+			existing, err := findReflections(ctx, secretsClient(client), test.name, "source")
+			if test.listErr != nil {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Equal(t, len(test.reflections), len(existing))
+			for ns, names := range test.reflections {
+				assert.ElementsMatch(t, names, existing[ns])
+			}
+		})
+	}
+}
+
+func TestPruneReflections(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		namespaces []string
+		existing   []string
+		remaining  []string
+		listErr    error
+	}{
+		{
+			"prunes namespaces that are no longer targeted",
+			[]string{"ns1"},
+			[]string{"ns1", "ns2", "ns3"},
+			[]string{"ns1"},
+			nil,
+		},
+		{
+			"prunes nothing when every namespace is targeted",
+			[]string{"ns1", "ns2"},
+			[]string{"ns1", "ns2"},
+			[]string{"ns1", "ns2"},
+			nil,
+		},
+		{
+			"prunes everything when nothing is targeted",
+			[]string{},
+			[]string{"ns1", "ns2"},
+			[]string{},
+			nil,
+		},
+		{
+			"fails when the reflections can't be found",
+			[]string{},
+			[]string{"ns1"},
+			[]string{"ns1"},
+			errors.New("some error"),
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			source := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "thing",
+					Namespace: "source",
+				},
+			}
+			objs := []runtime.Object{source}
+			for _, ns := range test.existing {
+				objs = append(objs, &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "thing",
+						Namespace: ns,
+						Labels:    reflectionOf("source", "thing"),
+						Annotations: map[string]string{
+							annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+							annotations.ReflectedFromAnnotation:   "source",
+						},
+					},
+				})
+			}
+			client := fake.NewSimpleClientset(objs...)
+			if test.listErr != nil {
+				client.PrependReactor("list", "*",
 					func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
-						return true, nil, test.secretErr
+						return true, nil, test.listErr
 					})
 			}
-			ns, err := findExistingSecretNamespaces(ctx, client.CoreV1(), test.name)
-			if test.listErr != nil || test.secretErr != nil {
+
+			err := pruneReflections(
+				ctx,
+				zerolog.Nop(),
+				secretsClient(client),
+				source,
+				test.namespaces,
+				annotations.TargetNames{},
+				2)
+			if test.listErr != nil {
 				assert.NotNil(t, err)
 				return
 			}
-			assert.Equal(t, test.namespaces, ns)
+			assert.Nil(t, err)
+
+			remaining, err := findReflections(ctx, secretsClient(client), "thing", "source")
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, test.remaining, remaining.namespaces())
+			_, err = client.CoreV1().Secrets("source").Get(ctx, "thing", metav1.GetOptions{})
+			assert.Nil(t, err)
 		})
 	}
 }
+
+func TestPruneRenamedReflections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reflection := func(name string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns1",
+				Labels:    reflectionOf("source", "thing"),
+				Annotations: map[string]string{
+					annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+					annotations.ReflectedFromAnnotation:   "source",
+					annotations.ReflectedNameAnnotation:   "thing",
+				},
+			},
+		}
+	}
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: "source",
+		},
+	}
+	client := fake.NewSimpleClientset(source, reflection("old-name"), reflection("new-name"))
+
+	require.Nil(t, pruneReflections(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		source,
+		[]string{"ns1"},
+		annotations.TargetNames{"ns1": "new-name"},
+		1))
+
+	remaining, err := findReflections(ctx, secretsClient(client), "thing", "source")
+	require.Nil(t, err)
+	assert.Equal(t, reflections{"ns1": {"new-name"}}, remaining)
+}
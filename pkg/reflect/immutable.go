@@ -0,0 +1,53 @@
+package reflect
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrorImmutable is used when an immutable reflection needs to be updated
+// but immutable reflections may not be recreated
+var ErrorImmutable = errors.New("reflection is immutable")
+
+// isImmutable checks if an object can't be updated once created
+func isImmutable(obj object) bool {
+	switch o := obj.(type) {
+	case *v1.Secret:
+		return o.Immutable != nil && *o.Immutable
+	case *v1.ConfigMap:
+		return o.Immutable != nil && *o.Immutable
+	case *unstructured.Unstructured:
+		immutable, found, err := unstructured.NestedBool(o.Object, "immutable")
+		return err == nil && found && immutable
+	default:
+		return false
+	}
+}
+
+// deleteImmutable deletes an immutable reflection so that it can be created
+// again with the changes to the original object. Immutable reflections are
+// left as they are, and skipped, if they may not be recreated.
+func deleteImmutable(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	name string,
+	namespace string,
+	recreateImmutable bool,
+) error {
+	if !recreateImmutable {
+		return errors.Wrapf(ErrorImmutable, "unable to update %s", client.Name())
+	}
+
+	logger.Info().Msgf("recreating immutable %s", client.Name())
+	err := client.Objects(namespace).Delete(ctx, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error while deleting immutable %s", client.Name())
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestIsImmutable(t *testing.T) {
+	immutable := true
+	mutable := false
+	u := networkPolicy("ns", nil)
+	u.Object["immutable"] = true
+
+	tests := []struct {
+		descrip   string
+		obj       object
+		immutable bool
+	}{
+		{"an immutable secret", &v1.Secret{Immutable: &immutable}, true},
+		{"a mutable secret", &v1.Secret{Immutable: &mutable}, false},
+		{"a secret without the flag", &v1.Secret{}, false},
+		{"an immutable config map", &v1.ConfigMap{Immutable: &immutable}, true},
+		{"a config map without the flag", &v1.ConfigMap{}, false},
+		{"an immutable unstructured object", u, true},
+		{"an unstructured object without the flag", networkPolicy("ns", nil), false},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.immutable, isImmutable(test.obj))
+		})
+	}
+}
+
+func TestReflectImmutable(t *testing.T) {
+	tests := []struct {
+		descrip   string
+		recreate  bool
+		deleteErr error
+		err       error
+		recreated bool
+	}{
+		{
+			"recreates an immutable reflection",
+			true,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"leaves an immutable reflection when recreation is disabled",
+			false,
+			nil,
+			ErrorImmutable,
+			false,
+		},
+		{
+			"fails to delete an immutable reflection",
+			true,
+			errors.New("some error"),
+			errors.New("some error"),
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			immutable := true
+			client := fake.NewSimpleClientset(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "thing",
+					Namespace: "target",
+					Annotations: map[string]string{
+						annotations.ReflectionHashAnnotation:  "old-hash",
+						annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+						annotations.ReflectedFromAnnotation:   "source",
+					},
+				},
+				Immutable: &immutable,
+				Data:      map[string][]byte{"key": []byte("old")},
+			})
+			if test.deleteErr != nil {
+				client.PrependReactor("delete", "secrets",
+					func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+						return true, nil, test.deleteErr
+					})
+			}
+
+			err := reflect(
+				ctx,
+				zerolog.New(bytes.NewBuffer([]byte{})),
+				secretsClient(client),
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "thing",
+						Namespace: "source",
+					},
+					Immutable: &immutable,
+					Data:      map[string][]byte{"key": []byte("new")},
+				},
+				"thing",
+				"new-hash",
+				test.recreate,
+				"target")
+			if test.err != nil {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.Is(test.err, ErrorImmutable), isSkippable(err))
+			} else {
+				require.Nil(t, err)
+			}
+
+			sec, err := client.CoreV1().Secrets("target").Get(ctx, "thing", metav1.GetOptions{})
+			require.Nil(t, err)
+			if test.recreated {
+				assert.Equal(t, []byte("new"), sec.Data["key"])
+				assert.Equal(t, "new-hash", sec.Annotations[annotations.ReflectionHashAnnotation])
+				return
+			}
+			assert.Equal(t, []byte("old"), sec.Data["key"])
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package reflect
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// ErrorKeyCollision is used when a renamed data key collides with
+// another key of the object
+var ErrorKeyCollision = errors.New("data keys collide")
+
+// filterKeys removes the data keys of the object which, according to the
+// object's annotations, are not reflected. Objects other than secrets
+// and config maps are left as they are.
+func filterKeys(obj object, objAnnotations map[string]string) {
+	keeps := func(key string) bool {
+		return annotations.KeepsKey(objAnnotations, key)
+	}
+
+	switch o := obj.(type) {
+	case *v1.Secret:
+		dropKeys(o.Data, keeps)
+		dropKeys(o.StringData, keeps)
+	case *v1.ConfigMap:
+		dropKeys(o.Data, keeps)
+		dropKeys(o.BinaryData, keeps)
+	}
+}
+
+// dropKeys deletes the keys of the data which are not kept
+func dropKeys[V any](data map[string]V, keeps func(string) bool) {
+	for key := range data {
+		if !keeps(key) {
+			delete(data, key)
+		}
+	}
+}
+
+// mapKeys renames the data keys of the object with the key map annotation.
+// Objects other than secrets and config maps are left as they are.
+func mapKeys(obj object, objAnnotations map[string]string) error {
+	keyMap, err := annotations.KeyMap(objAnnotations)
+	if err != nil || len(keyMap) == 0 {
+		return err
+	}
+
+	switch o := obj.(type) {
+	case *v1.Secret:
+		if o.Data, err = renameKeys(o.Data, keyMap); err != nil {
+			return err
+		}
+		o.StringData, err = renameKeys(o.StringData, keyMap)
+		return err
+	case *v1.ConfigMap:
+		if o.Data, err = renameKeys(o.Data, keyMap); err != nil {
+			return err
+		}
+		if o.BinaryData, err = renameKeys(o.BinaryData, keyMap); err != nil {
+			return err
+		}
+		// keys must be unique across the data and binary data of a config map
+		for key := range o.BinaryData {
+			if _, ok := o.Data[key]; ok {
+				return errors.Wrapf(ErrorKeyCollision, "key %q is in both data and binary data", key)
+			}
+		}
+	}
+	return nil
+}
+
+// renameKeys copies the data with its keys renamed by the key map
+func renameKeys[V any](data map[string]V, keyMap map[string]string) (map[string]V, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	renamed := make(map[string]V, len(data))
+	for key, value := range data {
+		if to, ok := keyMap[key]; ok {
+			key = to
+		}
+		if _, ok := renamed[key]; ok {
+			return nil, errors.Wrapf(ErrorKeyCollision, "more than one key is reflected as %q", key)
+		}
+		renamed[key] = value
+	}
+	return renamed, nil
+}
@@ -0,0 +1,180 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestFilterKeys(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		obj      object
+		expected object
+	}{
+		{
+			"keeps every key without annotations",
+			map[string]string{},
+			tlsSecret(),
+			tlsSecret(),
+		},
+		{
+			"keeps only the included keys of a secret",
+			map[string]string{annotations.KeysAnnotation: "ca.crt"},
+			tlsSecret(),
+			func() object {
+				s := tlsSecret()
+				s.Data = map[string][]byte{"ca.crt": []byte("some ca")}
+				return s
+			}(),
+		},
+		{
+			"drops the excluded keys of a secret",
+			map[string]string{annotations.KeysExcludeAnnotation: "tls.key,binary"},
+			tlsSecret(),
+			func() object {
+				s := tlsSecret()
+				s.Data = map[string][]byte{"ca.crt": []byte("some ca"), "tls.crt": []byte("some cert")}
+				return s
+			}(),
+		},
+		{
+			"filters the data of a config map",
+			map[string]string{annotations.KeysAnnotation: "a,c"},
+			&v1.ConfigMap{
+				Data:       map[string]string{"a": "a", "b": "b"},
+				BinaryData: map[string][]byte{"c": []byte("c"), "d": []byte("d")},
+			},
+			&v1.ConfigMap{
+				Data:       map[string]string{"a": "a"},
+				BinaryData: map[string][]byte{"c": []byte("c")},
+			},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			filterKeys(test.obj, test.ann)
+			assert.Equal(t, test.expected, test.obj)
+		})
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		ann      map[string]string
+		obj      object
+		expected object
+		err      error
+	}{
+		{
+			"renames the keys of a secret",
+			map[string]string{annotations.KeyMapAnnotation: "password=POSTGRES_PASSWORD,username=POSTGRES_USER"},
+			&v1.Secret{Data: map[string][]byte{"password": []byte("p"), "username": []byte("u"), "host": []byte("h")}},
+			&v1.Secret{Data: map[string][]byte{"POSTGRES_PASSWORD": []byte("p"), "POSTGRES_USER": []byte("u"), "host": []byte("h")}},
+			nil,
+		},
+		{
+			"swaps keys",
+			map[string]string{annotations.KeyMapAnnotation: "a=b,b=a"},
+			&v1.ConfigMap{Data: map[string]string{"a": "1", "b": "2"}},
+			&v1.ConfigMap{Data: map[string]string{"a": "2", "b": "1"}},
+			nil,
+		},
+		{
+			"fails on collisions with other keys",
+			map[string]string{annotations.KeyMapAnnotation: "password=host"},
+			&v1.Secret{Data: map[string][]byte{"password": []byte("p"), "host": []byte("h")}},
+			nil,
+			ErrorKeyCollision,
+		},
+		{
+			"fails on collisions across config map data",
+			map[string]string{annotations.KeyMapAnnotation: "a=b"},
+			&v1.ConfigMap{Data: map[string]string{"a": "1"}, BinaryData: map[string][]byte{"b": {0xff}}},
+			nil,
+			ErrorKeyCollision,
+		},
+		{
+			"fails on invalid key maps",
+			map[string]string{annotations.KeyMapAnnotation: "a=not valid"},
+			&v1.ConfigMap{Data: map[string]string{"a": "1"}},
+			nil,
+			annotations.ErrorInvalidKeyMap,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			err := mapKeys(test.obj, test.ann)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, test.expected, test.obj)
+		})
+	}
+}
+
+func TestReflectFilteredKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reflectSource := func(client *fake.Clientset, key []byte, resourceVersion string) *v1.Secret {
+		source := tlsSecret()
+		source.ResourceVersion = resourceVersion
+		source.Data["tls.key"] = key
+		source.Annotations = map[string]string{
+			annotations.ReflectAnnotation:     "true",
+			annotations.KeysExcludeAnnotation: "tls.key",
+			annotations.KeyMapAnnotation:      "tls.crt=cert",
+		}
+		require.Nil(t, reflectToNamespaces(
+			ctx,
+			zerolog.Nop(),
+			secretsClient(client),
+			nil,
+			source,
+			[]string{"target"},
+			nil,
+			namespaceLister(t),
+			false,
+			false,
+			1))
+		sec, err := client.CoreV1().Secrets("target").Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		return sec
+	}
+
+	client := fake.NewSimpleClientset()
+	first := reflectSource(client, []byte("some key"), "1")
+	assert.NotContains(t, first.Data, "tls.key")
+	assert.Contains(t, first.Data, "ca.crt")
+	assert.NotContains(t, first.Annotations, annotations.KeysExcludeAnnotation)
+	assert.NotContains(t, first.Annotations, annotations.KeyMapAnnotation)
+	assert.Equal(t, []byte("some cert"), first.Data["cert"])
+
+	// a change to an excluded key, which changes the resource version of the
+	// source, neither changes the hash nor updates the reflection
+	client.ClearActions()
+	second := reflectSource(client, []byte("rotated key"), "2")
+	assert.Equal(t,
+		first.Annotations[annotations.ReflectionHashAnnotation],
+		second.Annotations[annotations.ReflectionHashAnnotation])
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+}
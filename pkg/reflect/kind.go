@@ -0,0 +1,226 @@
+package reflect
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Kind is a kind of object that is reflected between namespaces
+type Kind string
+
+const (
+	// KindSecrets reflects secrets
+	KindSecrets Kind = "secrets"
+	// KindConfigMaps reflects config maps
+	KindConfigMaps Kind = "configmaps"
+)
+
+// ErrorInvalidKind is used when an unknown kind is given
+var ErrorInvalidKind = errors.New("invalid kind")
+
+// object is a namespaced object which can be reflected
+type object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// objectInterface operates on the objects of a kind in a single namespace
+type objectInterface interface {
+	Get(ctx context.Context, name string) (object, error)
+	Create(ctx context.Context, obj object) error
+	Update(ctx context.Context, obj object) error
+	Delete(ctx context.Context, name string) error
+}
+
+// kind is the client for a kind of object which can be reflected
+type kind interface {
+	// Name is the name of the kind used in logs and metrics
+	Name() string
+	// Objects operates on the objects in a namespace
+	Objects(namespace string) objectInterface
+	// List lists the objects in a namespace, or in every namespace
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) ([]object, error)
+	// ListWatch lists and watches the objects in a namespace, or in every namespace
+	ListWatch(namespace string) cache.ListerWatcher
+	// ObjectType is an empty object of the kind
+	ObjectType() runtime.Object
+}
+
+// newKind creates the client for a kind
+func newKind(k Kind, core corev1.CoreV1Interface) (kind, error) {
+	switch k {
+	case KindSecrets:
+		return secretKind{client: core, serviceAccounts: core, configMaps: core}, nil
+	case KindConfigMaps:
+		return configMapKind{client: core}, nil
+	default:
+		return nil, errors.Wrapf(ErrorInvalidKind, "%q", k)
+	}
+}
+
+// secretKind reflects secrets
+type secretKind struct {
+	client          corev1.SecretsGetter
+	serviceAccounts corev1.ServiceAccountsGetter
+	configMaps      corev1.ConfigMapsGetter
+}
+
+func (k secretKind) Name() string {
+	return "secret"
+}
+
+func (k secretKind) Objects(namespace string) objectInterface {
+	return secretObjects{client: k.client.Secrets(namespace)}
+}
+
+func (k secretKind) List(
+	ctx context.Context,
+	namespace string,
+	opts metav1.ListOptions,
+) ([]object, error) {
+	found, err := k.client.Secrets(namespace).List(ctx, opts)
+	if err != nil {
+		return []object{}, err
+	}
+	objs := []object{}
+	for i := range found.Items {
+		objs = append(objs, &found.Items[i])
+	}
+	return objs, nil
+}
+
+func (k secretKind) ListWatch(namespace string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return k.client.Secrets(namespace).List(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return k.client.Secrets(namespace).Watch(context.Background(), opts)
+		},
+	}
+}
+
+func (k secretKind) ObjectType() runtime.Object {
+	return &v1.Secret{}
+}
+
+func (k secretKind) ServiceAccounts(namespace string) corev1.ServiceAccountInterface {
+	return k.serviceAccounts.ServiceAccounts(namespace)
+}
+
+func (k secretKind) ConfigMaps() kind {
+	return projectionKind{configMapKind{client: k.configMaps}}
+}
+
+// secretObjects operates on the secrets in a namespace
+type secretObjects struct {
+	client corev1.SecretInterface
+}
+
+func (o secretObjects) Get(ctx context.Context, name string) (object, error) {
+	return o.client.Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o secretObjects) Create(ctx context.Context, obj object) error {
+	sec, ok := obj.(*v1.Secret)
+	if !ok {
+		return errors.New("could not convert object to secret")
+	}
+	_, err := o.client.Create(ctx, sec, metav1.CreateOptions{})
+	return err
+}
+
+func (o secretObjects) Update(ctx context.Context, obj object) error {
+	sec, ok := obj.(*v1.Secret)
+	if !ok {
+		return errors.New("could not convert object to secret")
+	}
+	_, err := o.client.Update(ctx, sec, metav1.UpdateOptions{})
+	return err
+}
+
+func (o secretObjects) Delete(ctx context.Context, name string) error {
+	return o.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// configMapKind reflects config maps
+type configMapKind struct {
+	client corev1.ConfigMapsGetter
+}
+
+func (k configMapKind) Name() string {
+	return "configmap"
+}
+
+func (k configMapKind) Objects(namespace string) objectInterface {
+	return configMapObjects{client: k.client.ConfigMaps(namespace)}
+}
+
+func (k configMapKind) List(
+	ctx context.Context,
+	namespace string,
+	opts metav1.ListOptions,
+) ([]object, error) {
+	found, err := k.client.ConfigMaps(namespace).List(ctx, opts)
+	if err != nil {
+		return []object{}, err
+	}
+	objs := []object{}
+	for i := range found.Items {
+		objs = append(objs, &found.Items[i])
+	}
+	return objs, nil
+}
+
+func (k configMapKind) ListWatch(namespace string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return k.client.ConfigMaps(namespace).List(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return k.client.ConfigMaps(namespace).Watch(context.Background(), opts)
+		},
+	}
+}
+
+func (k configMapKind) ObjectType() runtime.Object {
+	return &v1.ConfigMap{}
+}
+
+// configMapObjects operates on the config maps in a namespace
+type configMapObjects struct {
+	client corev1.ConfigMapInterface
+}
+
+func (o configMapObjects) Get(ctx context.Context, name string) (object, error) {
+	return o.client.Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o configMapObjects) Create(ctx context.Context, obj object) error {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		return errors.New("could not convert object to config map")
+	}
+	_, err := o.client.Create(ctx, cm, metav1.CreateOptions{})
+	return err
+}
+
+func (o configMapObjects) Update(ctx context.Context, obj object) error {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		return errors.New("could not convert object to config map")
+	}
+	_, err := o.client.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (o configMapObjects) Delete(ctx context.Context, name string) error {
+	return o.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
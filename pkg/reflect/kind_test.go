@@ -0,0 +1,128 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func secretsClient(client *fake.Clientset) kind {
+	return secretKind{client: client.CoreV1(), serviceAccounts: client.CoreV1(), configMaps: client.CoreV1()}
+}
+
+func configMapsClient(client *fake.Clientset) kind {
+	return configMapKind{client: client.CoreV1()}
+}
+
+func projectionsClient(client *fake.Clientset) kind {
+	return projectionKind{configMapKind{client: client.CoreV1()}}
+}
+
+func TestNewKind(t *testing.T) {
+	tests := []struct {
+		descrip string
+		kind    Kind
+		name    string
+		err     error
+	}{
+		{
+			"creates a secret client",
+			KindSecrets,
+			"secret",
+			nil,
+		},
+		{
+			"creates a config map client",
+			KindConfigMaps,
+			"configmap",
+			nil,
+		},
+		{
+			"fails on an unknown kind",
+			"pods",
+			"",
+			ErrorInvalidKind,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			k, err := newKind(test.kind, fake.NewSimpleClientset().CoreV1())
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, test.name, k.Name())
+		})
+	}
+}
+
+func TestReflectConfigMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: "source",
+			Annotations: map[string]string{
+				annotations.ReflectAnnotation: "true",
+			},
+		},
+		Data: map[string]string{
+			"some-key": "some data",
+		},
+	}
+	client := fake.NewSimpleClientset(source)
+
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		configMapsClient(client),
+		nil,
+		source,
+		[]string{"ns1", "ns2"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		2))
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		cm, err := client.CoreV1().ConfigMaps(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, source.Data, cm.Data)
+		assert.Equal(t, annotations.ReflectionOwned, cm.Annotations[annotations.ReflectionOwnerAnnotation])
+		assert.Equal(t, "source", cm.Annotations[annotations.ReflectedFromAnnotation])
+		assert.NotContains(t, cm.Annotations, annotations.ReflectAnnotation)
+	}
+
+	existing, err := findReflections(ctx, configMapsClient(client), "thing", "source")
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, existing.namespaces())
+
+	require.Nil(t, cascadeDelete(
+		ctx,
+		zerolog.Nop(),
+		configMapsClient(client),
+		existing,
+		2))
+	for _, ns := range existing.namespaces() {
+		_, err := client.CoreV1().ConfigMaps(ns).Get(ctx, "thing", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	}
+	_, err = client.CoreV1().ConfigMaps("source").Get(ctx, "thing", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,120 @@
+package reflect
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// DefaultDeniedLabels are the labels which are never reflected unless the
+// denied labels are configured, as GitOps tools track the objects they
+// manage with them and would prune or fight over the reflections
+var DefaultDeniedLabels = []string{
+	"app.kubernetes.io/instance",
+	"argocd.argoproj.io/instance",
+	"kustomize.toolkit.fluxcd.io/*",
+	"helm.toolkit.fluxcd.io/*",
+}
+
+// DefaultDeniedAnnotations are the annotations which are never reflected
+// unless the denied annotations are configured, as they describe how the
+// original object is managed rather than the object itself
+var DefaultDeniedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"argocd.argoproj.io/*",
+	"kustomize.toolkit.fluxcd.io/*",
+	"helm.toolkit.fluxcd.io/*",
+	"meta.helm.sh/*",
+}
+
+// ErrorInvalidMetadataPattern is used when a label or annotation pattern
+// is not a valid glob
+var ErrorInvalidMetadataPattern = errors.New("invalid metadata pattern")
+
+// metadataPolicy determines which labels and annotations of an object are
+// carried over to its reflections
+type metadataPolicy struct {
+	labels      keyPolicy
+	annotations keyPolicy
+}
+
+// keyPolicy determines which keys of a label or annotation map are kept
+type keyPolicy struct {
+	allowed []string
+	denied  []string
+}
+
+// newMetadataPolicy creates the policy from the allowed and denied label
+// and annotation keys, which may be globs. Every key is allowed if there are
+// no allowed keys, and the defaults are denied if the denied keys are nil.
+func newMetadataPolicy(
+	allowedLabels []string,
+	deniedLabels []string,
+	allowedAnnotations []string,
+	deniedAnnotations []string,
+) (metadataPolicy, error) {
+	if deniedLabels == nil {
+		deniedLabels = DefaultDeniedLabels
+	}
+	if deniedAnnotations == nil {
+		deniedAnnotations = DefaultDeniedAnnotations
+	}
+
+	for _, keys := range [][]string{allowedLabels, deniedLabels, allowedAnnotations, deniedAnnotations} {
+		for _, key := range keys {
+			if _, err := path.Match(key, ""); err != nil {
+				return metadataPolicy{}, errors.Wrapf(ErrorInvalidMetadataPattern, "%q", key)
+			}
+		}
+	}
+	return metadataPolicy{
+		labels:      keyPolicy{allowed: allowedLabels, denied: deniedLabels},
+		annotations: keyPolicy{allowed: allowedAnnotations, denied: deniedAnnotations},
+	}, nil
+}
+
+// sanitize removes the finalizers of the object, which would block the
+// deletion of its reflections, along with the labels and annotations which
+// are not carried over. The reflector's own annotations are always kept.
+func (p metadataPolicy) sanitize(obj object) {
+	obj.SetFinalizers(nil)
+
+	labels := obj.GetLabels()
+	p.labels.filter(labels)
+	obj.SetLabels(labels)
+
+	objAnnotations := obj.GetAnnotations()
+	for key := range objAnnotations {
+		if !strings.HasPrefix(key, annotations.Prefix+"/") && !p.annotations.keeps(key) {
+			delete(objAnnotations, key)
+		}
+	}
+	obj.SetAnnotations(objAnnotations)
+}
+
+// filter deletes the keys which are not kept
+func (p keyPolicy) filter(data map[string]string) {
+	dropKeys(data, p.keeps)
+}
+
+// keeps checks if a key is kept. Denied keys win over allowed keys.
+func (p keyPolicy) keeps(key string) bool {
+	if matchesAny(p.denied, key) {
+		return false
+	}
+	return len(p.allowed) == 0 || matchesAny(p.allowed, key)
+}
+
+// matchesAny checks if the key matches any of the globs
+func matchesAny(globs []string, key string) bool {
+	for _, glob := range globs {
+		// the globs are validated when the policy is created
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
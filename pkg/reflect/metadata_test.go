@@ -0,0 +1,93 @@
+package reflect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestSanitize(t *testing.T) {
+	labels := map[string]string{
+		"app.kubernetes.io/instance":       "my-app",
+		"app.kubernetes.io/name":           "my-app",
+		"kustomize.toolkit.fluxcd.io/name": "infra",
+		"team":                             "payments",
+	}
+	objAnnotations := map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		"argocd.argoproj.io/tracking-id":                   "my-app:/Secret:source/thing",
+		"meta.helm.sh/release-name":                        "my-app",
+		"custom.annotation.k8s.io":                         "very-custom",
+		annotations.ReflectAnnotation:                      "true",
+	}
+
+	tests := []struct {
+		descrip     string
+		policy      [][]string
+		labels      map[string]string
+		annotations map[string]string
+	}{
+		{
+			"removes the default denied metadata",
+			[][]string{nil, nil, nil, nil},
+			map[string]string{"app.kubernetes.io/name": "my-app", "team": "payments"},
+			map[string]string{"custom.annotation.k8s.io": "very-custom", annotations.ReflectAnnotation: "true"},
+		},
+		{
+			"keeps only the allowed metadata",
+			[][]string{{"team"}, {}, {"*.k8s.io"}, {}},
+			map[string]string{"team": "payments"},
+			map[string]string{"custom.annotation.k8s.io": "very-custom", annotations.ReflectAnnotation: "true"},
+		},
+		{
+			"denied metadata wins over allowed metadata",
+			[][]string{{"team", "app.kubernetes.io/*"}, {"app.kubernetes.io/instance"}, {}, {"*", "*/*"}},
+			map[string]string{"app.kubernetes.io/name": "my-app", "team": "payments"},
+			map[string]string{annotations.ReflectAnnotation: "true"},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			policy, err := newMetadataPolicy(test.policy[0], test.policy[1], test.policy[2], test.policy[3])
+			require.Nil(t, err)
+
+			obj := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "thing",
+					Namespace:   "source",
+					Finalizers:  []string{"example.com/finalizer"},
+					Labels:      map[string]string{},
+					Annotations: map[string]string{},
+				},
+			}
+			for key, value := range labels {
+				obj.Labels[key] = value
+			}
+			for key, value := range objAnnotations {
+				obj.Annotations[key] = value
+			}
+
+			policy.sanitize(obj)
+			assert.Empty(t, obj.Finalizers)
+			assert.Equal(t, test.labels, obj.Labels)
+			assert.Equal(t, test.annotations, obj.Annotations)
+		})
+	}
+}
+
+func TestNewMetadataPolicy(t *testing.T) {
+	_, err := newMetadataPolicy(nil, nil, []string{"["}, nil)
+	assert.ErrorIs(t, err, ErrorInvalidMetadataPattern)
+
+	policy, err := newMetadataPolicy(nil, nil, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, DefaultDeniedLabels, policy.labels.denied)
+	assert.Equal(t, DefaultDeniedAnnotations, policy.annotations.denied)
+}
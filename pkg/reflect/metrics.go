@@ -19,7 +19,37 @@ var (
 			Name:      "reflected_total",
 			Help:      "The number of total reflections since the start of the reflector",
 		},
-		[]string{"reflection_action", "secret", "success", "namespace"},
+		[]string{"reflection_action", "kind", "secret", "success", "namespace"},
+	)
+
+	reflectorRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: SubsystemReflections,
+			Name:      "rejected_total",
+			Help:      "The number of reflections rejected because the namespace does not accept the object",
+		},
+		[]string{"kind", "secret", "namespace"},
+	)
+
+	reflectorDenials = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: SubsystemReflections,
+			Name:      "denied_total",
+			Help:      "The number of reflections denied because the type of the secret is not allowed",
+		},
+		[]string{"kind", "secret", "type"},
+	)
+
+	reflectorCollisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: SubsystemReflections,
+			Name:      "collisions_total",
+			Help:      "The number of reflections skipped because another object already exists under the name",
+		},
+		[]string{"kind", "secret", "namespace"},
 	)
 
 	reflectorReflectionLatency = prometheus.NewHistogramVec(
@@ -30,26 +60,29 @@ var (
 			Help:      "The latency from when a reflection is detected, to when it is completely reflected",
 			Buckets:   prometheus.DefBuckets,
 		},
-		[]string{"secret"},
+		[]string{"kind", "secret"},
 	)
 
-	reflectorSecretLatency = prometheus.NewHistogramVec(
+	reflectorObjectLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: Namespace,
 			Subsystem: SubsystemReflections,
 			Name:      "reflect_latency",
-			Help:      "The latency for the reflection of a single secret",
+			Help:      "The latency for the reflection of a single object",
 			Buckets:   prometheus.DefBuckets,
 		},
-		[]string{"secret", "namespace"},
+		[]string{"kind", "secret", "namespace"},
 	)
 )
 
 //nolint:gochecknoinits // registering metrics on init is standard best practice
 func init() {
 	prometheus.MustRegister(reflectorReflections)
+	prometheus.MustRegister(reflectorRejections)
+	prometheus.MustRegister(reflectorDenials)
+	prometheus.MustRegister(reflectorCollisions)
 	prometheus.MustRegister(reflectorReflectionLatency)
-	prometheus.MustRegister(reflectorSecretLatency)
+	prometheus.MustRegister(reflectorObjectLatency)
 	// Add Go module build info.
 	prometheus.MustRegister(collectors.NewBuildInfoCollector())
 }
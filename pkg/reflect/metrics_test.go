@@ -10,28 +10,46 @@ import (
 func TestValidMetrics(t *testing.T) {
 	t.Run("reflection counter is correct", func(t *testing.T) {
 		t.Parallel()
-		vals := []string{"create", "secret", "false", "default"}
+		vals := []string{"create", "secret", "sec", "false", "default"}
 		reflectorReflections.WithLabelValues(vals...).Inc()
 		m, err := reflectorReflections.GetMetricWithLabelValues(vals...)
 		require.Nil(t, err)
-		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflected_total\", help: \"The number of total reflections since the start of the reflector\", constLabels: {}, variableLabels: {reflection_action,secret,success,namespace}}", m.Desc().String())
+		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflected_total\", help: \"The number of total reflections since the start of the reflector\", constLabels: {}, variableLabels: {reflection_action,kind,secret,success,namespace}}", m.Desc().String())
+	})
+
+	t.Run("rejection counter is correct", func(t *testing.T) {
+		t.Parallel()
+		vals := []string{"secret", "sec", "default"}
+		reflectorRejections.WithLabelValues(vals...).Inc()
+		m, err := reflectorRejections.GetMetricWithLabelValues(vals...)
+		require.Nil(t, err)
+		assert.Equal(t, "Desc{fqName: \"reflector_reflections_rejected_total\", help: \"The number of reflections rejected because the namespace does not accept the object\", constLabels: {}, variableLabels: {kind,secret,namespace}}", m.Desc().String())
+	})
+
+	t.Run("denial counter is correct", func(t *testing.T) {
+		t.Parallel()
+		vals := []string{"secret", "sec", "kubernetes.io/service-account-token"}
+		reflectorDenials.WithLabelValues(vals...).Inc()
+		m, err := reflectorDenials.GetMetricWithLabelValues(vals...)
+		require.Nil(t, err)
+		assert.Equal(t, "Desc{fqName: \"reflector_reflections_denied_total\", help: \"The number of reflections denied because the type of the secret is not allowed\", constLabels: {}, variableLabels: {kind,secret,type}}", m.Desc().String())
 	})
 
 	t.Run("reflection secret latency is correct", func(t *testing.T) {
 		t.Parallel()
-		vals := []string{"sec", "default"}
-		reflectorSecretLatency.WithLabelValues(vals...).Observe(3)
-		m, err := reflectorSecretLatency.MetricVec.GetMetricWithLabelValues(vals...)
+		vals := []string{"configmap", "sec", "default"}
+		reflectorObjectLatency.WithLabelValues(vals...).Observe(3)
+		m, err := reflectorObjectLatency.MetricVec.GetMetricWithLabelValues(vals...)
 		require.Nil(t, err)
-		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflect_latency\", help: \"The latency for the reflection of a single secret\", constLabels: {}, variableLabels: {secret,namespace}}", m.Desc().String())
+		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflect_latency\", help: \"The latency for the reflection of a single object\", constLabels: {}, variableLabels: {kind,secret,namespace}}", m.Desc().String())
 	})
 
 	t.Run("reflection latency is correct", func(t *testing.T) {
 		t.Parallel()
-		vals := []string{"sec"}
+		vals := []string{"configmap", "sec"}
 		reflectorReflectionLatency.WithLabelValues(vals...).Observe(10)
 		m, err := reflectorReflectionLatency.MetricVec.GetMetricWithLabelValues(vals...)
 		require.Nil(t, err)
-		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflection_latency\", help: \"The latency from when a reflection is detected, to when it is completely reflected\", constLabels: {}, variableLabels: {secret}}", m.Desc().String())
+		assert.Equal(t, "Desc{fqName: \"reflector_reflections_reflection_latency\", help: \"The latency from when a reflection is detected, to when it is completely reflected\", constLabels: {}, variableLabels: {kind,secret}}", m.Desc().String())
 	})
 }
@@ -0,0 +1,117 @@
+package reflect
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// namespaceHandler creates the handler for namespace events, which
+// queues up the objects that should be reflected to a namespace when
+// it is created, its labels change or the objects it pulls change
+func (r *reflector) namespaceHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*v1.Namespace); ok {
+				r.queueForNamespace(ns)
+				r.queuePulled(ns)
+			}
+		},
+		UpdateFunc: func(old interface{}, updated interface{}) {
+			oldNs, ok := old.(*v1.Namespace)
+			if !ok {
+				return
+			}
+			ns, ok := updated.(*v1.Namespace)
+			if !ok {
+				return
+			}
+			// only label changes can change which objects target a namespace,
+			// and only consent changes can change which objects it accepts
+			if oldNs.Annotations[annotations.PullAnnotation] !=
+				ns.Annotations[annotations.PullAnnotation] {
+				// objects that are no longer pulled may have to be pruned
+				r.queuePulled(oldNs)
+				r.queuePulled(ns)
+			}
+			if labels.Equals(oldNs.Labels, ns.Labels) &&
+				oldNs.Annotations[annotations.AcceptFromAnnotation] ==
+					ns.Annotations[annotations.AcceptFromAnnotation] {
+				return
+			}
+			r.queueForNamespace(ns)
+		},
+	}
+}
+
+// namespaceHandler creates the handler for namespace events which
+// passes every event on to the reflector of each kind
+func (rs *reflectors) namespaceHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for _, r := range rs.reflectors {
+				r.namespaceHandler().OnAdd(obj, false)
+			}
+		},
+		UpdateFunc: func(old interface{}, updated interface{}) {
+			for _, r := range rs.reflectors {
+				r.namespaceHandler().OnUpdate(old, updated)
+			}
+		},
+	}
+}
+
+// queueForNamespace adds every watched object that is reflected to the
+// namespace to the work queue
+func (r *reflector) queueForNamespace(ns *v1.Namespace) {
+	logger := r.logger.With().Str("reflectionNamespace", ns.Name).Logger()
+	for _, cached := range r.indexers.list() {
+		obj, ok := cached.(object)
+		if !ok || !annotations.ShouldReflect(obj.GetAnnotations()) {
+			continue
+		}
+
+		targeted, err := annotations.TargetsNamespace(
+			obj.GetAnnotations(), ns, r.excludeNamespaces)
+		if err != nil || !targeted {
+			continue
+		}
+
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		logger.Debug().
+			Str("object", key).
+			Msg("namespace is targeted by object, queueing")
+		// don't rate limit as this isn't a retry of the secret
+		r.queue.Add(key)
+	}
+}
+
+// queuePulled adds every watched object that the namespace pulls to
+// the work queue
+func (r *reflector) queuePulled(ns *v1.Namespace) {
+	logger := r.logger.With().Str("reflectionNamespace", ns.Name).Logger()
+	sources, err := annotations.PullSources(ns.Annotations)
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to parse pulled objects")
+		return
+	}
+
+	for _, key := range sources {
+		// objects that aren't watched would be treated as deleted
+		if _, exists, _ := r.indexers.getByKey(key); !exists {
+			logger.Debug().
+				Str("object", key).
+				Msg("pulled object is not watched, skipping")
+			continue
+		}
+		logger.Debug().
+			Str("object", key).
+			Msg("namespace pulls object, queueing")
+		r.queue.Add(key)
+	}
+}
@@ -0,0 +1,242 @@
+package reflect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func namespaceTestReflector(t *testing.T, excluded []string) *reflector {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, sec := range []*v1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "all",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.ReflectAnnotation:   "true",
+					annotations.NamespaceAnnotation: "*",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "prod",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.ReflectAnnotation:           "true",
+					annotations.NamespaceSelectorAnnotation: "env=prod",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "not-reflected",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.NamespaceAnnotation: "*",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "listed",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.ReflectAnnotation:   "true",
+					annotations.NamespaceAnnotation: "other",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "invalid",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.ReflectAnnotation:   "true",
+					annotations.NamespaceAnnotation: "regex:(",
+				},
+			},
+		},
+	} {
+		require.Nil(t, indexer.Add(sec))
+	}
+
+	limiter := workqueue.NewItemExponentialFailureRateLimiter(
+		1*time.Millisecond, 1*time.Millisecond)
+	return &reflector{
+		logger:            zerolog.Nop(),
+		queue:             workqueue.NewRateLimitingQueue(limiter),
+		indexers:          objectStores{v1.NamespaceAll: indexer},
+		excludeNamespaces: excluded,
+	}
+}
+
+func drainQueue(r *reflector) []string {
+	keys := []string{}
+	for r.queue.Len() > 0 {
+		key, _ := r.queue.Get()
+		keys = append(keys, key.(string))
+		r.queue.Done(key)
+	}
+	return keys
+}
+
+func TestNamespaceHandler(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		old      *v1.Namespace
+		ns       *v1.Namespace
+		excluded []string
+		queued   []string
+	}{
+		{
+			"a new namespace queues the secrets targeting it",
+			nil,
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "new",
+					Labels: map[string]string{"env": "prod"},
+				},
+			},
+			[]string{},
+			[]string{"source/all", "source/prod"},
+		},
+		{
+			"a new namespace without matching labels queues the wildcard secrets",
+			nil,
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "new",
+				},
+			},
+			[]string{},
+			[]string{"source/all"},
+		},
+		{
+			"a new globally excluded namespace queues nothing",
+			nil,
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "new",
+					Labels: map[string]string{"env": "prod"},
+				},
+			},
+			[]string{"new"},
+			[]string{},
+		},
+		{
+			"a label change queues the secrets targeting the namespace",
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "existing",
+					Labels: map[string]string{"env": "dev"},
+				},
+			},
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "existing",
+					Labels: map[string]string{"env": "prod"},
+				},
+			},
+			[]string{},
+			[]string{"source/all", "source/prod"},
+		},
+		{
+			"a consent change queues the secrets targeting the namespace",
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "existing",
+				},
+			},
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "existing",
+					Annotations: map[string]string{
+						annotations.AcceptFromAnnotation: "source/*",
+					},
+				},
+			},
+			[]string{},
+			[]string{"source/all"},
+		},
+		{
+			"a new namespace queues the watched secrets it pulls",
+			nil,
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "new",
+					Annotations: map[string]string{
+						annotations.PullAnnotation: "source/listed,unwatched/secret",
+					},
+				},
+			},
+			[]string{},
+			[]string{"source/all", "source/listed"},
+		},
+		{
+			"a pull change queues the secrets pulled before and after",
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "existing",
+					Annotations: map[string]string{
+						annotations.PullAnnotation: "source/listed",
+					},
+				},
+			},
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "existing",
+					Annotations: map[string]string{
+						annotations.PullAnnotation: "source/prod",
+					},
+				},
+			},
+			[]string{},
+			[]string{"source/listed", "source/prod"},
+		},
+		{
+			"an update without label changes queues nothing",
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "existing",
+					Labels: map[string]string{"env": "prod"},
+				},
+			},
+			&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "existing",
+					Labels:      map[string]string{"env": "prod"},
+					Annotations: map[string]string{"some": "annotation"},
+				},
+			},
+			[]string{},
+			[]string{},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			r := namespaceTestReflector(t, test.excluded)
+			defer r.queue.ShutDown()
+
+			handler := r.namespaceHandler()
+			if test.old == nil {
+				handler.OnAdd(test.ns, false)
+			} else {
+				handler.OnUpdate(test.old, test.ns)
+			}
+			assert.ElementsMatch(t, test.queued, drainQueue(r))
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package reflect
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// overridden is the reflection of an object into a namespace which has
+// overrides, along with its hash
+type overridden struct {
+	obj  object
+	hash string
+}
+
+// overrideIndex indexes the overrides by the object they override
+const overrideIndex = "overrideOf"
+
+// overrideIndexers are the indexers of the caches of overrides
+var overrideIndexers = cache.Indexers{
+	overrideIndex: func(obj interface{}) ([]string, error) {
+		if source := overrideOf(obj); source != "" {
+			return []string{source}, nil
+		}
+		return []string{}, nil
+	},
+}
+
+// overriding are the kinds of objects, by their names, which may override
+// the objects of a kind. Config maps may override secrets, but secrets never
+// override config maps, so that secret data is never reflected into one.
+var overriding = map[string][]string{
+	"secret":    {"configmap", "secret"},
+	"configmap": {"configmap"},
+}
+
+// overrideStores are the caches of the secrets and config maps in every
+// namespace, keyed by the name of their kind, which overrides are read from
+type overrideStores map[string]cache.Indexer
+
+// overridesOf finds the overrides of the object of the kind, by the namespace
+// they are in and ordered by their names
+func (s overrideStores) overridesOf(client kind, key string) map[string][]object {
+	found := []object{}
+	for _, kindName := range overriding[client.Name()] {
+		store, ok := s[kindName]
+		if !ok {
+			continue
+		}
+		indexed, err := store.ByIndex(overrideIndex, key)
+		if err != nil {
+			continue
+		}
+		for _, cached := range indexed {
+			if obj, ok := cached.(object); ok {
+				found = append(found, obj)
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].GetName() < found[j].GetName()
+	})
+
+	overrides := map[string][]object{}
+	for _, obj := range found {
+		overrides[obj.GetNamespace()] = append(overrides[obj.GetNamespace()], obj)
+	}
+	return overrides
+}
+
+// overrideOf is the object, by its key, which the object overrides. It is
+// empty for objects which aren't overrides, or whose annotation is invalid.
+func overrideOf(obj interface{}) string {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = unknown.Obj
+	}
+	o, ok := obj.(object)
+	if !ok {
+		return ""
+	}
+	source, err := annotations.OverrideOf(o.GetAnnotations())
+	if err != nil {
+		return ""
+	}
+	return source
+}
+
+// keepOverrides drops the data of the secrets and config maps which aren't
+// overrides before they are cached, as every namespace is watched for overrides
+func keepOverrides(obj interface{}) (interface{}, error) {
+	if overrideOf(obj) != "" {
+		return obj, nil
+	}
+	switch o := obj.(type) {
+	case *v1.Secret:
+		o.Data = nil
+		o.StringData = nil
+	case *v1.ConfigMap:
+		o.Data = nil
+		o.BinaryData = nil
+	}
+	return obj, nil
+}
+
+// overrideHandler creates the handler for events of the overrides of a kind,
+// given by its name, which queues up the objects they override and the
+// objects they overrode before they changed
+func (r *reflector) overrideHandler(kindName string) cache.ResourceEventHandlerFuncs {
+	overrides := false
+	for _, overriding := range overriding[r.kind.Name()] {
+		overrides = overrides || overriding == kindName
+	}
+	queueOverridden := func(obj interface{}) {
+		if source := overrideOf(obj); overrides && source != "" {
+			r.queueOverridden(source)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: queueOverridden,
+		UpdateFunc: func(old interface{}, updated interface{}) {
+			if overrideOf(old) != overrideOf(updated) {
+				queueOverridden(old)
+			}
+			queueOverridden(updated)
+		},
+		DeleteFunc: queueOverridden,
+	}
+}
+
+// overrideHandler creates the handler for events of the overrides of a kind
+// which passes every event on to the reflector of each kind
+func (rs *reflectors) overrideHandler(kindName string) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for _, r := range rs.reflectors {
+				r.overrideHandler(kindName).OnAdd(obj, false)
+			}
+		},
+		UpdateFunc: func(old interface{}, updated interface{}) {
+			for _, r := range rs.reflectors {
+				r.overrideHandler(kindName).OnUpdate(old, updated)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			for _, r := range rs.reflectors {
+				r.overrideHandler(kindName).OnDelete(obj)
+			}
+		},
+	}
+}
+
+// queueOverridden adds the overridden object to the work queue
+func (r *reflector) queueOverridden(key string) {
+	// objects that aren't watched would be treated as deleted
+	if _, exists, _ := r.indexers.getByKey(key); !exists {
+		return
+	}
+	r.logger.Debug().
+		Str("overridden", key).
+		Msg("override changed, queueing overridden object")
+	// don't rate limit as this isn't a retry of the object
+	r.queue.Add(key)
+}
+
+// overrideNamespaces layers the overrides in each namespace on top of the
+// object, which is left as it is
+func overrideNamespaces(
+	obj object,
+	hash string,
+	overrides map[string][]object,
+) (map[string]overridden, error) {
+	layered := map[string]overridden{}
+	for ns, nsOverrides := range overrides {
+		if len(nsOverrides) == 0 {
+			continue
+		}
+		overriddenObj, ok := obj.DeepCopyObject().(object)
+		if !ok {
+			return nil, errors.New("could not copy object")
+		}
+		values := []map[string]string{}
+		for _, override := range nsOverrides {
+			overrideValues := templateValues(override)
+			for key, value := range overrideValues {
+				setValue(overriddenObj, key, []byte(value))
+			}
+			values = append(values, overrideValues)
+		}
+
+		// the overrides are hashed along with the object, so that changes to
+		// either of them update the reflection
+		raw, err := json.Marshal(struct {
+			Hash      string
+			Overrides []map[string]string
+		}{hash, values})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal overrides")
+		}
+		layered[ns] = overridden{
+			obj:  overriddenObj,
+			hash: fmt.Sprintf("%x", sha256.Sum256(raw)),
+		}
+	}
+	return layered, nil
+}
@@ -0,0 +1,228 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func overrideIn(namespace string, name string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				annotations.OverrideOfAnnotation: "source/thing",
+			},
+		},
+		Data: data,
+	}
+}
+
+func TestOverrideNamespaces(t *testing.T) {
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "thing", Namespace: "source"},
+		Data: map[string][]byte{
+			"endpoint": []byte("us.example.com"),
+			"user":     []byte("admin"),
+		},
+	}
+
+	layered, err := overrideNamespaces(source, "hash", map[string][]object{
+		"eu": {
+			overrideIn("eu", "a", map[string][]byte{"endpoint": []byte("eu-1.example.com"), "region": []byte("eu")}),
+			overrideIn("eu", "b", map[string][]byte{"endpoint": []byte("eu-2.example.com")}),
+		},
+		"us": {},
+	})
+	require.Nil(t, err)
+	require.Contains(t, layered, "eu")
+	assert.NotContains(t, layered, "us")
+	assert.Equal(t, map[string][]byte{
+		"endpoint": []byte("eu-2.example.com"),
+		"region":   []byte("eu"),
+		"user":     []byte("admin"),
+	}, layered["eu"].obj.(*v1.Secret).Data)
+	// the object itself is left as it is
+	assert.Equal(t, []byte("us.example.com"), source.Data["endpoint"])
+	assert.NotEqual(t, "hash", layered["eu"].hash)
+
+	changed, err := overrideNamespaces(source, "hash", map[string][]object{
+		"eu": {overrideIn("eu", "a", map[string][]byte{"endpoint": []byte("eu-3.example.com")})},
+	})
+	require.Nil(t, err)
+	assert.NotEqual(t, layered["eu"].hash, changed["eu"].hash)
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "thing", Namespace: "source"},
+		Data:       map[string]string{"endpoint": "us.example.com"},
+	}
+	layered, err = overrideNamespaces(cm, "hash", map[string][]object{
+		"eu": {&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "override", Namespace: "eu"},
+			BinaryData: map[string][]byte{"endpoint": {0xff}},
+		}},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{}, layered["eu"].obj.(*v1.ConfigMap).Data)
+	assert.Equal(t, map[string][]byte{"endpoint": {0xff}}, layered["eu"].obj.(*v1.ConfigMap).BinaryData)
+}
+
+func TestOverridesOf(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, overrideIndexers)
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, overrideIndexers)
+	require.Nil(t, secrets.Add(overrideIn("eu", "b", nil)))
+	require.Nil(t, secrets.Add(secretIn("eu")))
+	require.Nil(t, configMaps.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:        "a",
+		Namespace:   "eu",
+		Annotations: map[string]string{annotations.OverrideOfAnnotation: "source/thing"},
+	}}))
+	stores := overrideStores{"secret": secrets, "configmap": configMaps}
+
+	names := func(overrides map[string][]object) map[string][]string {
+		found := map[string][]string{}
+		for ns, objs := range overrides {
+			for _, obj := range objs {
+				found[ns] = append(found[ns], obj.GetName())
+			}
+		}
+		return found
+	}
+	// config maps override secrets, ordered along with them by their names
+	assert.Equal(t, map[string][]string{"eu": {"a", "b"}},
+		names(stores.overridesOf(secretsClient(client), "source/thing")))
+	// but secrets never override config maps
+	assert.Equal(t, map[string][]string{"eu": {"a"}},
+		names(stores.overridesOf(configMapsClient(client), "source/thing")))
+	assert.Empty(t, stores.overridesOf(secretsClient(client), "source/other"))
+	assert.Empty(t, overrideStores{}.overridesOf(secretsClient(client), "source/thing"))
+}
+
+func TestKeepOverrides(t *testing.T) {
+	override := overrideIn("eu", "override", map[string][]byte{"key": []byte("value")})
+	kept, err := keepOverrides(override)
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"key": []byte("value")}, kept.(*v1.Secret).Data)
+
+	sec := secretIn("eu")
+	sec.Data = map[string][]byte{"key": []byte("value")}
+	kept, err = keepOverrides(sec)
+	require.Nil(t, err)
+	assert.Nil(t, kept.(*v1.Secret).Data)
+
+	kept, err = keepOverrides(&v1.ConfigMap{
+		Data:       map[string]string{"key": "value"},
+		BinaryData: map[string][]byte{"key": {0xff}},
+	})
+	require.Nil(t, err)
+	assert.Nil(t, kept.(*v1.ConfigMap).Data)
+	assert.Nil(t, kept.(*v1.ConfigMap).BinaryData)
+}
+
+func TestProcessOverride(t *testing.T) {
+	ctx := context.Background()
+	source := secretIn("source")
+	source.Annotations = map[string]string{
+		annotations.ReflectAnnotation:   "true",
+		annotations.NamespaceAnnotation: "eu,us",
+	}
+	source.Data = map[string][]byte{"endpoint": []byte("us.example.com")}
+	// overrides are found in the namespaces they are in, which aren't watched
+	override := overrideIn("eu", "thing-override", map[string][]byte{"endpoint": []byte("eu.example.com")})
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, overrideIndexers)
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, overrideIndexers)
+	require.Nil(t, secrets.Add(override))
+	client := fake.NewSimpleClientset()
+
+	limiter := workqueue.NewItemExponentialFailureRateLimiter(
+		1*time.Millisecond, 1*time.Millisecond)
+	r := reflector{
+		ctx:                ctx,
+		logger:             zerolog.Nop(),
+		core:               client.CoreV1(),
+		kind:               secretsClient(client),
+		queue:              workqueue.NewRateLimitingQueue(limiter),
+		indexers:           objectStores{"source": storeOf(t, source)},
+		overrides:          overrideStores{"secret": secrets, "configmap": configMaps},
+		nsLister:           namespaceLister(t),
+		reflectConcurrency: 1,
+	}
+	defer r.queue.ShutDown()
+	endpoint := func(ns string) string {
+		sec, err := client.CoreV1().Secrets(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		return string(sec.Data["endpoint"])
+	}
+
+	require.Nil(t, r.process("source/thing"))
+	assert.Equal(t, "eu.example.com", endpoint("eu"))
+	assert.Equal(t, "us.example.com", endpoint("us"))
+
+	// changed overrides queue the object they override
+	changed := override.DeepCopy()
+	changed.Data["endpoint"] = []byte("eu-2.example.com")
+	require.Nil(t, secrets.Update(changed))
+	r.overrideHandler("secret").OnUpdate(override, changed)
+	assert.Equal(t, []string{"source/thing"}, drainQueue(&r))
+	require.Nil(t, r.process("source/thing"))
+	assert.Equal(t, "eu-2.example.com", endpoint("eu"))
+
+	// config maps override secrets as well
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "thing-region",
+			Namespace:   "us",
+			Annotations: map[string]string{annotations.OverrideOfAnnotation: "source/thing"},
+		},
+		Data: map[string]string{"endpoint": "us-2.example.com"},
+	}
+	require.Nil(t, configMaps.Add(cm))
+	r.overrideHandler("configmap").OnAdd(cm, false)
+	assert.Equal(t, []string{"source/thing"}, drainQueue(&r))
+	require.Nil(t, r.process("source/thing"))
+	assert.Equal(t, "us-2.example.com", endpoint("us"))
+
+	// a deleted override queues the object it overrode
+	require.Nil(t, secrets.Delete(changed))
+	r.overrideHandler("secret").OnDelete(cache.DeletedFinalStateUnknown{Key: "eu/thing-override", Obj: changed})
+	assert.Equal(t, []string{"source/thing"}, drainQueue(&r))
+	require.Nil(t, r.process("source/thing"))
+	assert.Equal(t, "us.example.com", endpoint("eu"))
+
+	// objects which aren't watched, or overrides of unwatched objects, queue nothing
+	r.overrideHandler("secret").OnAdd(secretIn("eu"), false)
+	other := overrideIn("eu", "other", nil)
+	other.Annotations[annotations.OverrideOfAnnotation] = "elsewhere/thing"
+	r.overrideHandler("secret").OnAdd(other, false)
+	assert.Empty(t, drainQueue(&r))
+}
+
+func TestOverrideHandlerIgnoresSecretsForConfigMaps(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "thing", Namespace: "source"}}
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.Nil(t, store.Add(source))
+	r := reflector{
+		logger:   zerolog.Nop(),
+		kind:     configMapsClient(client),
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		indexers: objectStores{v1.NamespaceAll: store},
+	}
+	defer r.queue.ShutDown()
+
+	r.overrideHandler("secret").OnAdd(overrideIn("eu", "override", nil), false)
+	assert.Empty(t, drainQueue(&r))
+}
@@ -0,0 +1,105 @@
+package reflect
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// projectingKind is a kind whose objects can be projected into
+// config maps next to their reflections
+type projectingKind interface {
+	ConfigMaps() kind
+}
+
+// projectionKind is the kind of the config maps projected from secrets. Its
+// reflections are the projections, rather than reflections of config maps.
+type projectionKind struct {
+	configMapKind
+}
+
+// isProjectionKind checks if the kind is the kind of projected config maps
+func isProjectionKind(client kind) bool {
+	_, ok := client.(projectionKind)
+	return ok
+}
+
+// projectToConfigMap creates a config map, in the namespace of the secret and
+// with the same name, holding the projected keys of the secret. Keys which
+// the secret doesn't hold are left out. Nil is returned if nothing is projected.
+func projectToConfigMap(obj object, keys []string) object {
+	secret, ok := obj.(*v1.Secret)
+	if !ok || len(keys) == 0 {
+		return nil
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+			// tells the projection apart from the reflection of a config map
+			// with the same name as the secret
+			Annotations: map[string]string{
+				annotations.ProjectedFromAnnotation: secret.Namespace + "/" + secret.Name,
+			},
+		},
+	}
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok {
+			continue
+		}
+		// config map data must be valid UTF-8, anything else is kept as binary
+		if utf8.Valid(value) {
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[key] = string(value)
+			continue
+		}
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+		cm.BinaryData[key] = value
+	}
+	return cm
+}
+
+// reflectProjection reflects the config map projected from an object into the
+// namespace, with the same name, hashing and ownership as the object's reflection
+func reflectProjection(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	projection object,
+	name string,
+	recreateImmutable bool,
+	namespace string,
+) error {
+	projecting, ok := client.(projectingKind)
+	if !ok || projection == nil {
+		return nil
+	}
+
+	configMaps := projecting.ConfigMaps()
+	hash, err := hashObject(projection)
+	if err != nil {
+		return errors.Wrapf(err, "unable to hash %s", configMaps.Name())
+	}
+	return reflect(ctx, logger, configMaps, projection, name, hash, recreateImmutable, namespace)
+}
+
+// withProjections adds the kind of the projections of a kind's objects
+func withProjections(client kind) []kind {
+	projecting, ok := client.(projectingKind)
+	if !ok {
+		return []kind{client}
+	}
+	return []kind{client, projecting.ConfigMaps()}
+}
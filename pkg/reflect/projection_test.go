@@ -0,0 +1,271 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func tlsSecret() *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: "source",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":  []byte("some ca"),
+			"tls.crt": []byte("some cert"),
+			"tls.key": []byte("some key"),
+			"binary":  {0xff, 0xfe},
+		},
+	}
+}
+
+func TestProjectToConfigMap(t *testing.T) {
+	tests := []struct {
+		descrip string
+		obj     object
+		keys    []string
+		data    map[string]string
+		binary  map[string][]byte
+	}{
+		{
+			"projects the listed keys",
+			tlsSecret(),
+			[]string{"ca.crt", "tls.crt"},
+			map[string]string{"ca.crt": "some ca", "tls.crt": "some cert"},
+			nil,
+		},
+		{
+			"leaves out missing keys",
+			tlsSecret(),
+			[]string{"ca.crt", "missing"},
+			map[string]string{"ca.crt": "some ca"},
+			nil,
+		},
+		{
+			"projects invalid UTF-8 as binary data",
+			tlsSecret(),
+			[]string{"binary"},
+			nil,
+			map[string][]byte{"binary": {0xff, 0xfe}},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			projection := projectToConfigMap(test.obj, test.keys)
+			require.NotNil(t, projection)
+			cm, ok := projection.(*v1.ConfigMap)
+			require.True(t, ok)
+			assert.Equal(t, "thing", cm.Name)
+			assert.Equal(t, "source", cm.Namespace)
+			assert.Equal(t, "source/thing", cm.Annotations[annotations.ProjectedFromAnnotation])
+			assert.Equal(t, test.data, cm.Data)
+			assert.Equal(t, test.binary, cm.BinaryData)
+		})
+	}
+
+	t.Run("projects nothing without keys", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, projectToConfigMap(tlsSecret(), []string{}))
+	})
+
+	t.Run("projects nothing from config maps", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, projectToConfigMap(&v1.ConfigMap{}, []string{"ca.crt"}))
+	})
+}
+
+func TestReflectProjection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := tlsSecret()
+	source.Annotations = map[string]string{
+		annotations.ReflectAnnotation:            "true",
+		annotations.ProjectToConfigMapAnnotation: "ca.crt",
+	}
+	client := fake.NewSimpleClientset()
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
+		source,
+		[]string{"ns1", "ns2"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		2))
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		_, err := client.CoreV1().Secrets(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		cm, err := client.CoreV1().ConfigMaps(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, map[string]string{"ca.crt": "some ca"}, cm.Data)
+		assert.Equal(t, annotations.ReflectionOwned, cm.Annotations[annotations.ReflectionOwnerAnnotation])
+		assert.Equal(t, "source", cm.Annotations[annotations.ReflectedFromAnnotation])
+		assert.NotEmpty(t, cm.Annotations[annotations.ReflectionHashAnnotation])
+		assert.NotContains(t, cm.Annotations, annotations.ProjectToConfigMapAnnotation)
+	}
+
+	existing, err := findReflections(ctx, projectionsClient(client), "thing", "source")
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, existing.namespaces())
+	// projections aren't reflections of a config map with the same name
+	existing, err = findReflections(ctx, configMapsClient(client), "thing", "source")
+	require.Nil(t, err)
+	assert.Empty(t, existing)
+}
+
+func TestReflectProjectionCollision(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the reflection of the config map source/thing
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+				annotations.ReflectionHashAnnotation:  "some-hash",
+				annotations.ReflectedFromAnnotation:   "source",
+			},
+		},
+		Data: map[string]string{"ca.crt": "config map ca"},
+	})
+	source := tlsSecret()
+	source.Annotations = map[string]string{
+		annotations.ReflectAnnotation:            "true",
+		annotations.ProjectToConfigMapAnnotation: "ca.crt",
+	}
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
+		source,
+		[]string{"ns1"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		1))
+
+	cm, err := client.CoreV1().ConfigMaps("ns1").Get(ctx, "thing", metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"ca.crt": "config map ca"}, cm.Data)
+	assert.NotContains(t, cm.Annotations, annotations.ProjectedFromAnnotation)
+}
+
+func TestProcessCascadeDeletesProjections(t *testing.T) {
+	ctx := context.Background()
+	source := tlsSecret()
+	source.Annotations = map[string]string{
+		annotations.ReflectAnnotation:            "true",
+		annotations.NamespaceAnnotation:          "ns1,ns2",
+		annotations.ProjectToConfigMapAnnotation: "ca.crt",
+	}
+	store := storeOf(t, source)
+	client := fake.NewSimpleClientset(
+		// the reflection of the config map source/thing, rather than a projection
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "thing", Namespace: "ns3", Labels: reflectionOf("source", "thing"), Annotations: map[string]string{
+			annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+			annotations.ReflectedFromAnnotation:   "source",
+		}}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "thing", Namespace: "unowned"}},
+	)
+
+	r := reflector{
+		ctx:                ctx,
+		logger:             zerolog.Nop(),
+		core:               client.CoreV1(),
+		kind:               secretsClient(client),
+		indexers:           objectStores{v1.NamespaceAll: store},
+		nsLister:           namespaceLister(t),
+		cascadeDelete:      true,
+		reflectConcurrency: 1,
+	}
+	require.Nil(t, r.process("source/thing"))
+	require.Nil(t, store.Delete(source))
+	require.Nil(t, r.process("source/thing"))
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		_, err := client.CoreV1().Secrets(ns).Get(ctx, "thing", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().ConfigMaps(ns).Get(ctx, "thing", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	}
+	for _, ns := range []string{"ns3", "unowned"} {
+		_, err := client.CoreV1().ConfigMaps(ns).Get(ctx, "thing", metav1.GetOptions{})
+		assert.Nil(t, err)
+	}
+}
+
+func TestProcessCleansUpProjectionsAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	source := tlsSecret()
+	source.Annotations = map[string]string{
+		annotations.ReflectAnnotation:            "true",
+		annotations.NamespaceAnnotation:          "ns1,ns2",
+		annotations.ProjectToConfigMapAnnotation: "ca.crt",
+	}
+	client := fake.NewSimpleClientset()
+	// every reflector starts out without knowing what was reflected before
+	newReflector := func(store cache.Indexer) *reflector {
+		return &reflector{
+			ctx:                ctx,
+			logger:             zerolog.Nop(),
+			core:               client.CoreV1(),
+			kind:               secretsClient(client),
+			indexers:           objectStores{v1.NamespaceAll: store},
+			nsLister:           namespaceLister(t),
+			prune:              true,
+			cascadeDelete:      true,
+			reflectConcurrency: 1,
+		}
+	}
+	projections := func() []string {
+		found, err := client.CoreV1().ConfigMaps(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		require.Nil(t, err)
+		namespaces := []string{}
+		for _, cm := range found.Items {
+			namespaces = append(namespaces, cm.Namespace)
+		}
+		return namespaces
+	}
+	require.Nil(t, newReflector(storeOf(t, source)).process("source/thing"))
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, projections())
+
+	// projections are pruned once the secret stops projecting keys
+	stopped := source.DeepCopy()
+	delete(stopped.Annotations, annotations.ProjectToConfigMapAnnotation)
+	require.Nil(t, newReflector(storeOf(t, stopped)).process("source/thing"))
+	assert.Empty(t, projections())
+
+	require.Nil(t, newReflector(storeOf(t, source)).process("source/thing"))
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, projections())
+
+	// and deleted along with it
+	require.Nil(t, newReflector(storeOf(t)).process("source/thing"))
+	assert.Empty(t, projections())
+	secrets, err := client.CoreV1().Secrets(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	require.Nil(t, err)
+	assert.Empty(t, secrets.Items)
+}
@@ -0,0 +1,66 @@
+package reflect
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// pullingNamespaces finds the namespaces which pull the object and
+// which the object allows to pull it. Namespaces pulling an object that
+// doesn't allow them are logged and skipped.
+func pullingNamespaces(
+	logger zerolog.Logger,
+	nsLister corelisters.NamespaceLister,
+	obj object,
+	excluded []string,
+) ([]string, error) {
+	pulling := []string{}
+	if !annotations.IsPullable(obj.GetAnnotations()) {
+		return pulling, nil
+	}
+
+	namespaces, err := nsLister.List(labels.Everything())
+	if err != nil {
+		return []string{}, errors.Wrap(err, "unable to list namespaces")
+	}
+
+	for _, ns := range namespaces {
+		if ns.Name == obj.GetNamespace() ||
+			!annotations.PullsSecret(ns.Annotations, obj.GetNamespace(), obj.GetName()) {
+			continue
+		}
+
+		allowed, err := annotations.AllowsPull(obj.GetAnnotations(), ns.Name, excluded)
+		if err != nil {
+			return []string{}, err
+		}
+		if !allowed {
+			logger.Info().
+				Str("reflectionNamespace", ns.Name).
+				Msg("object does not allow namespace to pull it, skipping")
+			continue
+		}
+		pulling = append(pulling, ns.Name)
+	}
+	return pulling, nil
+}
+
+// mergeNamespaces appends the namespaces which aren't in the list yet
+func mergeNamespaces(namespaces []string, others []string) []string {
+	namespaceSet := map[string]struct{}{}
+	for _, ns := range namespaces {
+		namespaceSet[ns] = struct{}{}
+	}
+	for _, ns := range others {
+		if _, ok := namespaceSet[ns]; ok {
+			continue
+		}
+		namespaceSet[ns] = struct{}{}
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
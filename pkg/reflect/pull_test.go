@@ -0,0 +1,181 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func pullNamespace(name string, pull string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				annotations.PullAnnotation: pull,
+			},
+		},
+	}
+}
+
+func TestPullingNamespaces(t *testing.T) {
+	tests := []struct {
+		descrip   string
+		allowPull string
+		excluded  []string
+		pulling   []string
+		err       bool
+	}{
+		{
+			"a secret without the allow pull annotation is not pulled",
+			"",
+			[]string{},
+			[]string{},
+			false,
+		},
+		{
+			"a secret is pulled by every allowed namespace",
+			"*",
+			[]string{},
+			[]string{"team-a", "team-b"},
+			false,
+		},
+		{
+			"a secret is only pulled by allowed namespaces",
+			"team-a",
+			[]string{},
+			[]string{"team-a"},
+			false,
+		},
+		{
+			"a secret is not pulled by excluded namespaces",
+			"*",
+			[]string{"team-b"},
+			[]string{"team-a"},
+			false,
+		},
+		{
+			"an invalid allow pull annotation fails",
+			"regex:(",
+			[]string{},
+			[]string{},
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			sec := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "registry-creds",
+					Namespace:   "shared",
+					Annotations: map[string]string{},
+				},
+			}
+			if test.allowPull != "" {
+				sec.Annotations[annotations.AllowPullAnnotation] = test.allowPull
+			}
+
+			pulling, err := pullingNamespaces(
+				zerolog.New(bytes.NewBuffer([]byte{})),
+				namespaceLister(t,
+					pullNamespace("team-a", "shared/registry-creds"),
+					pullNamespace("team-b", "shared/ca-bundle,shared/registry-creds"),
+					pullNamespace("team-c", "shared/ca-bundle"),
+					pullNamespace("shared", "shared/registry-creds"),
+					&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "no-annotation"}},
+				),
+				sec,
+				test.excluded)
+			assert.Equal(t, test.err, err != nil)
+			assert.ElementsMatch(t, test.pulling, pulling)
+		})
+	}
+}
+
+func TestMergeNamespaces(t *testing.T) {
+	assert.Equal(t,
+		[]string{"a", "b", "c"},
+		mergeNamespaces([]string{"a", "b"}, []string{"b", "c", "c"}))
+	assert.Equal(t, []string{"a"}, mergeNamespaces([]string{}, []string{"a"}))
+}
+
+func TestProcessPull(t *testing.T) {
+	tests := []struct {
+		descrip     string
+		annotations map[string]string
+		reflected   []string
+	}{
+		{
+			"a pullable secret is reflected to the pulling namespace",
+			map[string]string{
+				annotations.AllowPullAnnotation: "*",
+			},
+			[]string{"team-a"},
+		},
+		{
+			"a pullable secret is reflected to the pulling and targeted namespaces",
+			map[string]string{
+				annotations.ReflectAnnotation:   "true",
+				annotations.NamespaceAnnotation: "team-b",
+				annotations.AllowPullAnnotation: "team-a",
+			},
+			[]string{"team-a", "team-b"},
+		},
+		{
+			"a secret that isn't pullable is not reflected to the pulling namespace",
+			map[string]string{},
+			[]string{},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			sec := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "registry-creds",
+					Namespace:   "shared",
+					Annotations: test.annotations,
+				},
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.Nil(t, indexer.Add(sec))
+
+			client := fake.NewSimpleClientset()
+			r := reflector{
+				ctx:      ctx,
+				logger:   zerolog.Nop(),
+				core:     client.CoreV1(),
+				kind:     secretsClient(client),
+				indexers: objectStores{v1.NamespaceAll: indexer},
+				nsLister: namespaceLister(t,
+					pullNamespace("team-a", "shared/registry-creds"),
+					&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+				),
+				reflectConcurrency: 1,
+			}
+			require.Nil(t, r.process("shared/registry-creds"))
+
+			reflected := []string{}
+			for _, ns := range []string{"team-a", "team-b"} {
+				if _, err := client.CoreV1().Secrets(ns).Get(
+					ctx, "registry-creds", metav1.GetOptions{}); err == nil {
+					reflected = append(reflected, ns)
+				}
+			}
+			assert.ElementsMatch(t, test.reflected, reflected)
+		})
+	}
+}
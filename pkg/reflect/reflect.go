@@ -3,17 +3,19 @@ package reflect
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
-
 	v1 "k8s.io/api/core/v1"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/havulv/reflector/pkg/annotations"
 )
@@ -21,9 +23,14 @@ import (
 func reflectToNamespaces(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretsGetter,
-	sec *v1.Secret,
+	client kind,
+	recorder record.EventRecorder,
+	obj object,
 	namespaces []string,
+	overrides map[string][]object,
+	nsLister corelisters.NamespaceLister,
+	requireConsent bool,
+	recreateImmutable bool,
 	concurrency int,
 ) error {
 	// shortcircuit if we have the best case of `do nothing`
@@ -33,10 +40,20 @@ func reflectToNamespaces(
 		return nil
 	}
 
+	// only reflect into the namespaces that accept the object and
+	// can still have objects created in them
+	namespaces = filterConsenting(logger, nsLister, requireConsent, client, obj, namespaces)
+	namespaces = filterTerminating(logger, nsLister, client, obj, namespaces)
+	if len(namespaces) == 0 {
+		logger.Info().
+			Msg("no namespaces can accept the object, skipping")
+		return nil
+	}
+
 	start := time.Now()
 	defer func() {
 		reflectorReflectionLatency.
-			WithLabelValues(sec.Name).
+			WithLabelValues(client.Name(), obj.GetName()).
 			Observe(time.Until(start).Seconds())
 	}()
 
@@ -44,45 +61,196 @@ func reflectToNamespaces(
 	// we do this quite early, because we:
 	// * Already parsed out the namespaces
 	// * Do read only ops // copies from here on out, that don't need these annotations
-	// * Don't want to generate updates for secrets in existing namespaces when only
+	// * Don't want to generate updates for objects in existing namespaces when only
 	//   the namespace annotation changes
 
-	// We don't do it earlier because we want to avoid mutating this secret outside of
+	// We don't do it earlier because we want to avoid mutating this object outside of
 	// the context of reflection. We don't do a deep copy because we don't need to
 	// needlessly waste memory.
-	delete(sec.Annotations, annotations.ReflectAnnotation)
-	delete(sec.Annotations, annotations.NamespaceAnnotation)
+	// Unstructured objects return a copy of their annotations, so they are set back.
+	objAnnotations := obj.GetAnnotations()
+	targetNames, err := annotations.ParseTargetNames(objAnnotations)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse target names")
+	}
+	// e.g. `*` also targets the namespace of the object itself, which would
+	// otherwise collide with the object on every reflection
+	if namespaces = withoutObject(obj, targetNames, namespaces); len(namespaces) == 0 {
+		logger.Info().
+			Msg("no namespaces besides the object's own, skipping")
+		return nil
+	}
+	templates, err := annotations.Templates(objAnnotations)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse templates")
+	}
+	targetType, err := annotations.TargetType(objAnnotations)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse target type")
+	}
+	// the priority is carried over to the reflections, where it is compared
+	if _, err := annotations.Priority(objAnnotations); err != nil {
+		return errors.Wrap(err, "unable to parse priority")
+	}
+	serviceAccounts := annotations.ServiceAccounts(objAnnotations)
+	projection := projectToConfigMap(obj, annotations.ProjectedKeys(objAnnotations))
+	// templates are rendered from all of the data, including filtered keys
+	values := templateValues(obj)
+	// the data is filtered and renamed before hashing, and the hash leaves
+	// out server metadata, so that only changes to the reflected keys update
+	// the reflections
+	filterKeys(obj, objAnnotations)
+	if err := mapKeys(obj, objAnnotations); err != nil {
+		return errors.Wrap(err, "unable to map keys")
+	}
+	// the type is converted before hashing, so that changing it updates the reflections
+	convertType(obj, targetType)
+	// the status isn't hashed either, as it is neither reflected nor
+	// changed by the reflector
+	stripStatus(obj)
+	annotations.RemoveSourceAnnotations(objAnnotations)
+	obj.SetAnnotations(objAnnotations)
 
 	// hash the og -- TODO is crc64 good enough here?
+	hash, err := hashObject(obj)
+	if err != nil {
+		return errors.Wrapf(err, "unable to hash %s", client.Name())
+	}
+	// templates are rendered once, after hashing, so that every namespace
+	// gets the same rendered data
+	if len(templates) > 0 {
+		hash, err = hashTemplates(hash, templates, values)
+		if err != nil {
+			return errors.Wrapf(err, "unable to hash %s", client.Name())
+		}
+		if err := renderTemplates(obj, templates, values); err != nil {
+			return errors.Wrap(err, "unable to render templates")
+		}
+	}
+	// converted secrets are validated once the templates which may create
+	// the keys their type requires are rendered
+	// a secret lacking them won't get them on a retry, so every namespace is skipped
+	if targetType != "" {
+		if err := validateType(obj); err != nil {
+			err = errors.Wrap(err, "unable to convert type")
+			for _, ns := range namespaces {
+				skipNamespace(logger, client, obj, ns, err)
+			}
+			return nil
+		}
+	}
+	// overrides are layered on top of the rendered data of each namespace
+	layered, err := overrideNamespaces(obj, hash, overrides)
+	if err != nil {
+		return errors.Wrap(err, "unable to override data")
+	}
 	return batchOverNamespaces(
 		concurrency,
 		namespaces,
-		reflectLambda(
-			ctx, logger, client, sec,
-			fmt.Sprintf("%x", sha256.Sum256([]byte(sec.String())))))
+		reflectLambda(ctx, logger, client, recorder, obj, targetNames, hash, layered, serviceAccounts, projection, recreateImmutable))
+}
+
+// hashObject hashes the payload which is reflected: the data and type of
+// secrets and config maps, or every field but the metadata and status of
+// other objects, along with the labels and annotations which are carried
+// over and the namespace and name the reflections are annotated with.
+// Server populated metadata, such as the resource version, is never hashed.
+func hashObject(obj object) (string, error) {
+	payload := struct {
+		Namespace   string            `json:"namespace"`
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+		Content     interface{}       `json:"content"`
+	}{
+		Namespace:   obj.GetNamespace(),
+		Name:        obj.GetName(),
+		Labels:      obj.GetLabels(),
+		Annotations: obj.GetAnnotations(),
+	}
+	switch o := obj.(type) {
+	case *v1.Secret:
+		payload.Content = struct {
+			Type       v1.SecretType     `json:"type"`
+			Immutable  *bool             `json:"immutable,omitempty"`
+			Data       map[string][]byte `json:"data,omitempty"`
+			StringData map[string]string `json:"stringData,omitempty"`
+		}{o.Type, o.Immutable, o.Data, o.StringData}
+	case *v1.ConfigMap:
+		payload.Content = struct {
+			Immutable  *bool             `json:"immutable,omitempty"`
+			Data       map[string]string `json:"data,omitempty"`
+			BinaryData map[string][]byte `json:"binaryData,omitempty"`
+		}{o.Immutable, o.Data, o.BinaryData}
+	case *unstructured.Unstructured:
+		content := map[string]interface{}{}
+		for field, value := range o.Object {
+			if field != "metadata" && field != "status" {
+				content[field] = value
+			}
+		}
+		payload.Content = content
+	default:
+		return "", errors.Errorf("unable to hash %T", obj)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal object")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw)), nil
+}
+
+// withoutObject removes the namespace of the object from the namespaces if
+// the reflection in it would have the same name as the object
+func withoutObject(obj object, targetNames annotations.TargetNames, namespaces []string) []string {
+	filtered := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if ns == obj.GetNamespace() && targetNames.Name(ns, obj.GetName()) == obj.GetName() {
+			continue
+		}
+		filtered = append(filtered, ns)
+	}
+	return filtered
 }
 
 func reflectLambda(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretsGetter,
-	sec *v1.Secret,
+	client kind,
+	recorder record.EventRecorder,
+	obj object,
+	targetNames annotations.TargetNames,
 	hash string,
+	layered map[string]overridden,
+	serviceAccounts []string,
+	projection object,
+	recreateImmutable bool,
 ) func(wg *sync.WaitGroup, ns string, errChan chan error) {
 	return func(wg *sync.WaitGroup, ns string, errChan chan error) {
-		reflectSecret(
-			ctx, logger.With().Str("reflectionNamespace", ns).Logger(),
-			wg, client, sec, hash, ns, errChan)
+		name := targetNames.Name(ns, obj.GetName())
+		nsObj, nsHash := obj, hash
+		if override, ok := layered[ns]; ok {
+			nsObj, nsHash = override.obj, override.hash
+		}
+		reflectObject(
+			ctx, logger.With().Str("reflectionNamespace", ns).Str("reflectionName", name).Logger(),
+			wg, client, recorder, nsObj, name, nsHash, serviceAccounts, projection, recreateImmutable, ns, errChan)
 	}
 }
 
-func reflectSecret(
+func reflectObject(
 	ctx context.Context,
 	logger zerolog.Logger,
 	wg *sync.WaitGroup,
-	client corev1.SecretsGetter,
-	sec *v1.Secret,
+	client kind,
+	recorder record.EventRecorder,
+	obj object,
+	name string,
 	hash string,
+	serviceAccounts []string,
+	projection object,
+	recreateImmutable bool,
 	ns string,
 	errChan chan error,
 ) {
@@ -90,18 +258,35 @@ func reflectSecret(
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := instrumentedReflect(
+		err := instrumentedReflect(
 			ctx,
 			logger,
-			client.Secrets(ns),
-			sec,
+			client,
+			obj,
+			name,
 			hash,
+			recreateImmutable,
 			ns,
-		); err != nil {
+		)
+		if err == nil {
+			err = attachToServiceAccounts(ctx, logger, client, name, ns, serviceAccounts)
+		}
+		if err == nil {
+			err = reflectProjection(ctx, logger, client, projection, name, recreateImmutable, ns)
+		}
+		if err != nil {
+			// retrying won't help, so don't fail the other namespaces
+			if isSkippable(err) {
+				skipNamespace(logger, client, obj, ns, err)
+				if errors.Is(err, ErrorCollision) {
+					recordCollision(recorder, client, obj, ns, err)
+				}
+				return
+			}
 			logger.Error().Err(err).Msg("unable to reflect")
 			errChan <- errors.Wrap(
 				err,
-				"error while reflecting secret to namespace")
+				"error while reflecting object to namespace")
 		}
 	}()
 }
@@ -109,123 +294,194 @@ func reflectSecret(
 func instrumentedReflect(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretInterface,
-	og *v1.Secret,
+	client kind,
+	og object,
+	name string,
 	hash string,
+	recreateImmutable bool,
 	namespace string,
 ) error {
 	start := time.Now()
 	defer func() {
-		reflectorSecretLatency.
-			WithLabelValues(og.Name, og.Namespace).
+		reflectorObjectLatency.
+			WithLabelValues(client.Name(), og.GetName(), og.GetNamespace()).
 			Observe(time.Until(start).Seconds())
 	}()
-	return reflect(ctx, logger, client, og, hash, namespace)
+	return reflect(ctx, logger, client, og, name, hash, recreateImmutable, namespace)
 }
 
 func reflect(
 	ctx context.Context,
 	logger zerolog.Logger,
-	client corev1.SecretInterface,
-	og *v1.Secret,
+	client kind,
+	og object,
+	name string,
 	hash string,
+	recreateImmutable bool,
 	namespace string,
 ) error {
+	objects := client.Objects(namespace)
+
 	// reflect to the new namespace
 	// if it exists, then pull the resource and check if we own it
-	reflected, err := client.Get(ctx, og.Name, metav1.GetOptions{})
+	reflected, err := objects.Get(ctx, name)
 	exists := !apierrors.IsNotFound(err)
 	if err != nil && exists {
-		logger.Error().Err(err).Msg("error while fetching secret from reflection namespace")
-		return errors.Wrap(err, "error while getting reflected secret")
+		logger.Error().Err(err).Msg("error while fetching object from reflection namespace")
+		return errors.Wrap(err, "error while getting reflected object")
+	}
+
+	// never clobber another object which has the same name, unless it is the
+	// reflection of an object which the object outranks. An unchanged hash
+	// means that the reflection is already one of the object. Reflections of
+	// an earlier object with the same namespace and name, i.e. another UID,
+	// are taken over, so that recreated objects keep their reflections.
+	if exists && reflected.GetAnnotations()[annotations.ReflectionHashAnnotation] != hash &&
+		!isReflectionOf(reflected, og) {
+		if !outranks(og, reflected) {
+			return collision(client, reflected)
+		}
+		logger.Info().
+			Str("reflectedFrom", reflected.GetAnnotations()[annotations.ReflectedFromAnnotation]).
+			Msg("object outranks the reflection of another object, replacing it")
 	}
 
 	// if it does exist, check the hash to see if we need to update
-	if exists && !secretNeedsUpdate(logger, reflected, hash) {
+	if exists && !needsUpdate(logger, reflected, hash, og) {
 		return nil
 	}
 
+	// immutable objects can't be updated, only replaced
+	if exists && isImmutable(reflected) {
+		if err := deleteImmutable(ctx, logger, client, name, namespace, recreateImmutable); err != nil {
+			return err
+		}
+		exists = false
+	}
+	// nor can the type of a secret
+	if exists && changesType(reflected, og) {
+		if err := deleteChangedType(ctx, logger, client, name, namespace); err != nil {
+			return err
+		}
+		exists = false
+	}
+
 	logger.Debug().
 		Bool("create", !exists).
 		Bool("update", exists).
-		Str("secret", og.Name).
+		Str("object", name).
 		Str("namespace", namespace).
-		Msg("performing action for reflected secret")
-	return createOrUpdateSecret(
-		ctx,
-		client,
-		createNewSecret(og, hash, namespace),
-		exists)
+		Msg("performing action for reflected object")
+	toReflect := createNewObject(og, name, hash, namespace)
+	// updates are made to the version of the reflection that was checked,
+	// as custom resources can't be updated unconditionally
+	if exists {
+		toReflect.SetResourceVersion(reflected.GetResourceVersion())
+	}
+	return createOrUpdateObject(ctx, client, toReflect, exists)
 }
 
-func secretNeedsUpdate(
+func needsUpdate(
 	logger zerolog.Logger,
-	secret *v1.Secret,
+	obj object,
 	hash string,
+	og object,
 ) bool {
 	// if there is no hash then we know we don't really own it and can short circuit
-	reflectHash, ok := secret.Annotations[annotations.ReflectionHashAnnotation]
+	reflectHash, ok := obj.GetAnnotations()[annotations.ReflectionHashAnnotation]
 	if !ok {
-		logger.Info().Msg("We don't own this secret: not updating")
+		logger.Info().Msg("We don't own this object: not updating")
 		return false
 	}
 
-	if reflectHash == hash {
-		logger.Debug().Str("hash", hash).Msg("No changes to secret, not updating")
+	// reflections without the reflection of label, e.g. those of earlier
+	// versions, are updated so that they can be found by it
+	if reflectHash == hash &&
+		obj.GetLabels()[annotations.ReflectionOfLabel] == annotations.ReflectionOf(og.GetNamespace(), og.GetName()) {
+		logger.Debug().Str("hash", hash).Msg("No changes to object, not updating")
 		return false
 	}
 
 	// ownership is explicit -- if there is no ownership annotation then skip
-	return annotations.CanOperate(secret.Annotations)
+	return annotations.CanOperate(obj.GetAnnotations())
 }
 
-func createNewSecret(
-	secret *v1.Secret,
+func createNewObject(
+	obj object,
+	name string,
 	hash string,
 	namespace string,
-) *v1.Secret {
+) object {
 	// DeepCopy and fix the annotations
-	toReflect := secret.DeepCopy()
-	toReflect.Namespace = namespace
+	toReflect, _ := obj.DeepCopyObject().(object)
+	toReflect.SetName(name)
+	toReflect.SetNamespace(namespace)
 
 	// we can't set resource version on objects to be created
-	toReflect.ObjectMeta.ResourceVersion = ""
-	toReflect.ObjectMeta.UID = ""
+	toReflect.SetResourceVersion("")
+	toReflect.SetUID("")
+	// owners can't be referenced across namespaces, and the fields are
+	// managed by whoever changes the reflection
+	toReflect.SetOwnerReferences(nil)
+	toReflect.SetManagedFields(nil)
 
-	toReflect.Annotations[annotations.ReflectedFromAnnotation] = secret.Namespace
-	toReflect.Annotations[annotations.ReflectedAtAnnotation] = fmt.Sprintf("%d", time.Now().UTC().UnixNano())
-	toReflect.Annotations[annotations.ReflectionHashAnnotation] = hash
-	toReflect.Annotations[annotations.ReflectionOwnerAnnotation] = annotations.ReflectionOwned
+	objAnnotations := toReflect.GetAnnotations()
+	if objAnnotations == nil {
+		objAnnotations = map[string]string{}
+	}
+	objAnnotations[annotations.ReflectedFromAnnotation] = obj.GetNamespace()
+	objAnnotations[annotations.ReflectedNameAnnotation] = obj.GetName()
+	// aggregates don't originate from a single object
+	if uid := obj.GetUID(); uid != "" {
+		objAnnotations[annotations.ReflectedUIDAnnotation] = string(uid)
+	}
+	objAnnotations[annotations.ReflectedAtAnnotation] = fmt.Sprintf("%d", time.Now().UTC().UnixNano())
+	objAnnotations[annotations.ReflectionHashAnnotation] = hash
+	objAnnotations[annotations.ReflectionOwnerAnnotation] = annotations.ReflectionOwned
+	toReflect.SetAnnotations(objAnnotations)
+
+	// the reflections of an object are listed by their label
+	objLabels := toReflect.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[annotations.ReflectionOfLabel] = annotations.ReflectionOf(obj.GetNamespace(), obj.GetName())
+	toReflect.SetLabels(objLabels)
 	return toReflect
 }
 
-func createOrUpdateSecret(
+func createOrUpdateObject(
 	ctx context.Context,
-	client corev1.SecretInterface,
-	sec *v1.Secret,
+	client kind,
+	obj object,
 	exists bool,
 ) (err error) {
-	labels := []string{"create", sec.Name, "true", sec.Namespace}
+	labels := []string{"create", client.Name(), obj.GetName(), "true", obj.GetNamespace()}
 	defer func() {
+		// skipped namespaces are counted by the caller
+		if isSkippable(err) {
+			return
+		}
 		if err != nil {
-			labels[2] = "false"
+			labels[3] = "false"
 		}
 		reflectorReflections.WithLabelValues(labels...).Inc()
 	}()
 
+	objects := client.Objects(obj.GetNamespace())
 	// update if it does exist, create if it does not
 	if exists {
 		labels[0] = "update"
-		_, err = client.Update(ctx, sec, metav1.UpdateOptions{})
+		err = objects.Update(ctx, obj)
 		if err != nil {
-			return errors.Wrap(err, "error while updating secret")
+			return errors.Wrapf(err, "error while updating %s", client.Name())
 		}
 		return nil
 	}
 
-	_, err = client.Create(ctx, sec, metav1.CreateOptions{})
+	err = objects.Create(ctx, obj)
 	if err != nil {
-		return errors.Wrap(err, "error while creating secret")
+		return errors.Wrapf(err, "error while creating %s", client.Name())
 	}
 	return nil
 }
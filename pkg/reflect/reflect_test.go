@@ -54,15 +54,16 @@ func TestReflectToNamespaces(t *testing.T) {
 			}
 
 			err := reflectToNamespaces(
-				ctx, zerolog.New(bytes.NewBuffer([]byte{})),
-				client.CoreV1(),
+				ctx, zerolog.Nop(),
+				secretsClient(client),
+				nil,
 				&v1.Secret{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:        "this",
 						Namespace:   "thing",
 						Annotations: map[string]string{},
 					},
-				}, namespaces, 2)
+				}, namespaces, nil, namespaceLister(t), false, false, 2)
 
 			if test.earlyExit {
 				assert.Nil(t, err)
@@ -73,6 +74,60 @@ func TestReflectToNamespaces(t *testing.T) {
 	}
 }
 
+func TestReflectTargetName(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a reflection of another secret already has the target name in ns-c
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "ns-c",
+			Annotations: map[string]string{
+				annotations.ReflectionHashAnnotation:  "other-hash",
+				annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+				annotations.ReflectedFromAnnotation:   "other",
+			},
+		},
+	})
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: "source",
+			Annotations: map[string]string{
+				annotations.ReflectAnnotation:    "true",
+				annotations.TargetNameAnnotation: "ns-a:db-creds,ns-b:legacy-db,creds",
+			},
+		},
+	}
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
+		source,
+		[]string{"ns-a", "ns-b", "ns-c", "ns-d"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		2))
+
+	for ns, name := range map[string]string{"ns-a": "db-creds", "ns-b": "legacy-db", "ns-d": "creds"} {
+		sec, err := client.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, "thing", sec.Annotations[annotations.ReflectedNameAnnotation])
+		assert.NotContains(t, sec.Annotations, annotations.TargetNameAnnotation)
+	}
+	other, err := client.CoreV1().Secrets("ns-c").Get(ctx, "creds", metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, "other-hash", other.Annotations[annotations.ReflectionHashAnnotation])
+
+	existing, err := findReflections(ctx, secretsClient(client), "thing", "source")
+	require.Nil(t, err)
+	assert.Equal(t, reflections{"ns-a": {"db-creds"}, "ns-b": {"legacy-db"}, "ns-d": {"creds"}}, existing)
+}
+
 func TestReflectLambda(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -84,15 +139,16 @@ func TestReflectLambda(t *testing.T) {
 
 	f := reflectLambda(
 		ctx,
-		zerolog.New(bytes.NewBuffer([]byte{})),
-		client.CoreV1(),
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
 		&v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        "this",
 				Namespace:   "thing",
 				Annotations: map[string]string{},
 			},
-		}, "some-hash")
+		}, annotations.TargetNames{}, "some-hash", nil, []string{}, nil, false)
 	assert.NotNil(t, f)
 
 	wg := &sync.WaitGroup{}
@@ -109,7 +165,7 @@ func TestReflectLambda(t *testing.T) {
 	}
 }
 
-func TestReflectSecret(t *testing.T) {
+func TestReflectObject(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	client := fake.NewSimpleClientset()
@@ -120,11 +176,12 @@ func TestReflectSecret(t *testing.T) {
 
 	wg := &sync.WaitGroup{}
 	errChan := make(chan error, 2)
-	reflectSecret(
+	reflectObject(
 		ctx,
-		zerolog.New(bytes.NewBuffer([]byte{})),
+		zerolog.Nop(),
 		wg,
-		client.CoreV1(),
+		secretsClient(client),
+		nil,
 		&v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        "this",
@@ -132,7 +189,11 @@ func TestReflectSecret(t *testing.T) {
 				Annotations: map[string]string{},
 			},
 		},
+		"this",
 		"hash",
+		[]string{},
+		nil,
+		false,
 		"blergh",
 		errChan)
 	wg.Wait()
@@ -155,7 +216,7 @@ func TestInstrumentedReflect(t *testing.T) {
 	assert.Nil(t, instrumentedReflect(
 		ctx,
 		zerolog.New(bytes.NewBuffer([]byte{})),
-		fake.NewSimpleClientset().CoreV1().Secrets("blergh"),
+		secretsClient(fake.NewSimpleClientset()),
 		&v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:        name,
@@ -163,9 +224,11 @@ func TestInstrumentedReflect(t *testing.T) {
 				Annotations: map[string]string{},
 			},
 		},
+		name,
 		"hash",
+		false,
 		"blergh"))
-	m, err := reflectorSecretLatency.MetricVec.GetMetricWithLabelValues(name, ns)
+	m, err := reflectorObjectLatency.MetricVec.GetMetricWithLabelValues("secret", name, ns)
 	require.Nil(t, err)
 	metric := &dto.Metric{}
 	require.Nil(t, m.Write(metric))
@@ -209,6 +272,7 @@ func TestReflect(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "x",
 					Namespace: "new-ns",
+					Labels:    reflectionOf("new-ns", "x"),
 					Annotations: map[string]string{
 						annotations.ReflectionHashAnnotation: "some-hash",
 					},
@@ -242,9 +306,11 @@ func TestReflect(t *testing.T) {
 			err := reflect(
 				ctx,
 				zerolog.New(buf),
-				client.CoreV1().Secrets("new-ns"),
+				secretsClient(client),
 				test.secret,
+				test.secret.Name,
 				"some-hash",
+				false,
 				"new-ns")
 			if test.getErr != nil {
 				assert.NotNil(t, err)
@@ -262,7 +328,7 @@ func TestReflect(t *testing.T) {
 	}
 }
 
-func TestSecretNeedsUpdate(t *testing.T) {
+func TestNeedsUpdate(t *testing.T) {
 	tests := []struct {
 		d   string
 		sec *v1.Secret
@@ -283,6 +349,9 @@ func TestSecretNeedsUpdate(t *testing.T) {
 			"an unchanged hash does not update",
 			&v1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						annotations.ReflectionOfLabel: annotations.ReflectionOf("source", "secret"),
+					},
 					Annotations: map[string]string{
 						annotations.ReflectionHashAnnotation:  "some-hash",
 						annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
@@ -291,6 +360,18 @@ func TestSecretNeedsUpdate(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"an unchanged hash without the reflection of label does update",
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotations.ReflectionHashAnnotation:  "some-hash",
+						annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+					},
+				},
+			},
+			true,
+		},
 		{
 			"changed hash does update",
 			&v1.Secret{
@@ -324,15 +405,16 @@ func TestSecretNeedsUpdate(t *testing.T) {
 			assert.Equal(
 				t,
 				test.res,
-				secretNeedsUpdate(
+				needsUpdate(
 					zerolog.New(bytes.NewBuffer([]byte{})),
 					test.sec,
-					"some-hash"))
+					"some-hash",
+					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "source"}}))
 		})
 	}
 }
 
-func TestCreateNewSecret(t *testing.T) {
+func TestCreateNewObject(t *testing.T) {
 	tests := []struct {
 		d  string
 		og *v1.Secret
@@ -354,15 +436,18 @@ func TestCreateNewSecret(t *testing.T) {
 			t.Parallel()
 			hash := "this"
 			namespace := "blergh2"
-			s := createNewSecret(test.og, hash, namespace)
-			assert.Equal(t, s.Annotations[annotations.ReflectionHashAnnotation], hash)
-			assert.Greater(t, len(s.Annotations[annotations.ReflectedAtAnnotation]), 0)
-			assert.Equal(t, s.Annotations[annotations.ReflectedFromAnnotation], test.og.Namespace)
+			s := createNewObject(test.og, "renamed", hash, namespace)
+			assert.Equal(t, "renamed", s.GetName())
+			assert.Equal(t, namespace, s.GetNamespace())
+			assert.Equal(t, test.og.Name, s.GetAnnotations()[annotations.ReflectedNameAnnotation])
+			assert.Equal(t, s.GetAnnotations()[annotations.ReflectionHashAnnotation], hash)
+			assert.Greater(t, len(s.GetAnnotations()[annotations.ReflectedAtAnnotation]), 0)
+			assert.Equal(t, s.GetAnnotations()[annotations.ReflectedFromAnnotation], test.og.Namespace)
 		})
 	}
 }
 
-func TestCreateOrUpdateSecret(t *testing.T) {
+func TestCreateOrUpdateObject(t *testing.T) {
 	tests := []struct {
 		d      string
 		exists bool
@@ -448,9 +533,9 @@ func TestCreateOrUpdateSecret(t *testing.T) {
 					})
 			}
 
-			err := createOrUpdateSecret(
+			err := createOrUpdateObject(
 				ctx,
-				client.CoreV1().Secrets("monitoring"),
+				secretsClient(client),
 				test.secret,
 				test.exists)
 			if test.err != nil {
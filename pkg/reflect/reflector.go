@@ -1,5 +1,5 @@
 // Package reflect contains the business logic for reflecting
-// secrets between namespaces. Importantly, it tackles
+// secrets and config maps between namespaces. Importantly, it tackles
 // implementing the functions that will work on the workqueue
 // that is constructed.
 //
@@ -14,11 +14,16 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/havulv/reflector/pkg/annotations"
@@ -26,58 +31,256 @@ import (
 )
 
 // Reflector is the core reflector interface which takes care of
-// watching and syncing secrets
+// watching and syncing secrets and config maps
 type Reflector interface {
 	Start(ctx context.Context) error
 }
 
+// Options are the options which configure a reflector
+type Options struct {
+	// Kinds are the kinds of objects to reflect. Only secrets are
+	// reflected if there are none.
+	Kinds []Kind
+	// Resources are any other namespaced resources to reflect, which are
+	// reflected as unstructured objects with the dynamic client
+	Resources []schema.GroupVersionResource
+	// Dynamic is the client used to reflect resources. It is only
+	// required when there are resources to reflect.
+	Dynamic dynamic.Interface
+	// Namespaces are the namespaces to watch for secrets to reflect.
+	// All namespaces are watched if there are none.
+	Namespaces []string
+	// ReflectConcurrency is the number of namespaces a secret is
+	// reflected to concurrently
+	ReflectConcurrency int
+	// WorkerConcurrency is the number of workers picking work off
+	// of the work queue concurrently
+	WorkerConcurrency int
+	// Retries is the number of times reflecting a secret is retried
+	Retries int
+	// CascadeDelete deletes reflected secrets when the original
+	// secret is deleted
+	CascadeDelete bool
+	// ExcludeNamespaces are namespace names, globs and regexes which
+	// are never reflected to, regardless of a secret's annotations
+	ExcludeNamespaces []string
+	// Prune deletes reflected secrets from namespaces that are no longer
+	// targeted by the original secret, unless the secret opts out
+	Prune bool
+	// UnreflectPolicy determines what happens to reflected secrets when
+	// the original secret stops being reflected. Defaults to freezing them.
+	UnreflectPolicy UnreflectPolicy
+	// RecreateImmutable deletes and recreates immutable reflections when
+	// the original object changes. Otherwise they are left as they are.
+	RecreateImmutable bool
+	// AllowedSecretTypes are the only types of secrets which are reflected.
+	// Every type is allowed if there are none.
+	AllowedSecretTypes []string
+	// DeniedSecretTypes are the types of secrets which are never reflected,
+	// even if they are allowed. DefaultDeniedSecretTypes are denied if nil.
+	DeniedSecretTypes []string
+	// AllowedLabels are the only labels, given as keys or globs, which are
+	// carried over to reflections. Every label is allowed if there are none.
+	AllowedLabels []string
+	// DeniedLabels are the labels which are never carried over to
+	// reflections, even if they are allowed. DefaultDeniedLabels are denied if nil.
+	DeniedLabels []string
+	// AllowedAnnotations are the only annotations, given as keys or globs, which
+	// are carried over to reflections. Every annotation is allowed if there are none.
+	AllowedAnnotations []string
+	// DeniedAnnotations are the annotations which are never carried over to
+	// reflections, even if they are allowed. DefaultDeniedAnnotations are denied if nil.
+	DeniedAnnotations []string
+	// RequireConsent only reflects secrets into namespaces which accept
+	// them through the accept from annotation. Otherwise namespaces
+	// without the annotation accept every secret.
+	RequireConsent bool
+	// Overrides watches secrets and config maps in every namespace for
+	// overrides, which are layered on the reflections in their namespace
+	Overrides bool
+}
+
+// reflector reflects the objects of a single kind
 type reflector struct {
 	ctx                context.Context
 	core               corev1.CoreV1Interface
+	kind               kind
+	recorder           record.EventRecorder
 	logger             zerolog.Logger
 	workerConcurrency  int
 	reflectConcurrency int
 	retries            int
 	cascadeDelete      bool
+	prune              bool
+	unreflectPolicy    UnreflectPolicy
+	excludeNamespaces  []string
+	requireConsent     bool
+	recreateImmutable  bool
+	secretTypes        secretTypePolicy
+	metadata           metadataPolicy
+	aggregates         membership
+	overrides          overrideStores
+	nsLister           corelisters.NamespaceLister
 	queue              workqueue.RateLimitingInterface
-	indexer            cache.Indexer
-	controller         cache.Controller
+	indexers           objectStores
+	controllers        []cache.Controller
 	hasSynced          func() bool
 }
 
-// NewReflector creates a new reflector for reflecting secrets to other namespaces
+// reflectors reflects every kind of object with a reflector per kind,
+// sharing the namespace informer between them
+type reflectors struct {
+	logger       zerolog.Logger
+	reflectors   []*reflector
+	nsController cache.Controller
+	// overrideControllers watch every namespace for overrides
+	overrideControllers []cache.Controller
+	events              corev1.EventInterface
+	broadcaster         record.EventBroadcaster
+}
+
+// NewReflector creates a new reflector for reflecting secrets and config maps
+// to other namespaces
 func NewReflector(
 	logger zerolog.Logger,
 	clientset kubernetes.Interface,
-	reflectConcurrency int,
-	workerConcurrency int,
-	retries int,
-	cascadeDelete bool,
-	namespace string,
+	opts Options,
 ) (Reflector, error) {
+	reflectConcurrency := opts.ReflectConcurrency
 	if reflectConcurrency < 1 {
 		reflectConcurrency = 1
 	}
 
+	workerConcurrency := opts.WorkerConcurrency
 	if workerConcurrency < 1 {
 		workerConcurrency = 1
 	}
 
-	queue, indexer, controller := queue.CreateSecretsWorkQueue(
-		clientset.CoreV1(), namespace)
-
-	return &reflector{
-		core:               clientset.CoreV1(),
-		cascadeDelete:      cascadeDelete,
-		logger:             logger,
-		queue:              queue,
-		retries:            retries,
-		indexer:            indexer,
-		controller:         controller,
-		reflectConcurrency: reflectConcurrency,
-		workerConcurrency:  workerConcurrency,
-		hasSynced:          controller.HasSynced,
-	}, nil
+	if err := annotations.ValidateNamespaces(opts.ExcludeNamespaces); err != nil {
+		return nil, errors.Wrap(err, "invalid excluded namespaces")
+	}
+
+	unreflectPolicy, err := parseUnreflectPolicy(opts.UnreflectPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = []Kind{KindSecrets}
+	}
+
+	clients := []kind{}
+	seen := map[Kind]struct{}{}
+	for _, k := range kinds {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+
+		client, err := newKind(k, clientset.CoreV1())
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	if len(opts.Resources) > 0 && opts.Dynamic == nil {
+		return nil, ErrorNoDynamicClient
+	}
+	seenResources := map[schema.GroupVersionResource]struct{}{}
+	for _, resource := range opts.Resources {
+		if _, ok := seenResources[resource]; ok {
+			continue
+		}
+		seenResources[resource] = struct{}{}
+		clients = append(clients, resourceKind{client: opts.Dynamic, resource: resource})
+	}
+
+	secretTypes := newSecretTypePolicy(opts.AllowedSecretTypes, opts.DeniedSecretTypes)
+	metadata, err := newMetadataPolicy(
+		opts.AllowedLabels, opts.DeniedLabels, opts.AllowedAnnotations, opts.DeniedAnnotations)
+	if err != nil {
+		return nil, err
+	}
+
+	// events are recorded on the objects which are reflected, e.g. for collisions
+	broadcaster := record.NewBroadcaster()
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "reflector"})
+
+	rs := &reflectors{
+		logger:      logger,
+		events:      clientset.CoreV1().Events(v1.NamespaceAll),
+		broadcaster: broadcaster,
+	}
+	for _, client := range clients {
+		workQueue, indexers, controllers := queue.CreateWorkQueue(
+			client.ListWatch, client.ObjectType(), opts.Namespaces)
+		rs.reflectors = append(rs.reflectors, &reflector{
+			core:               clientset.CoreV1(),
+			kind:               client,
+			recorder:           recorder,
+			cascadeDelete:      opts.CascadeDelete,
+			prune:              opts.Prune,
+			unreflectPolicy:    unreflectPolicy,
+			requireConsent:     opts.RequireConsent,
+			recreateImmutable:  opts.RecreateImmutable,
+			secretTypes:        secretTypes,
+			metadata:           metadata,
+			excludeNamespaces:  opts.ExcludeNamespaces,
+			logger:             logger.With().Str("kind", client.Name()).Logger(),
+			queue:              workQueue,
+			retries:            opts.Retries,
+			indexers:           indexers,
+			controllers:        controllers,
+			reflectConcurrency: reflectConcurrency,
+			workerConcurrency:  workerConcurrency,
+		})
+	}
+
+	// watch namespaces so that objects are reflected to namespaces
+	// created after the object was last changed
+	nsIndexer, nsController := queue.CreateNamespaceInformer(
+		clientset.CoreV1(), rs.namespaceHandler())
+	rs.nsController = nsController
+
+	// overrides are in the namespaces objects are reflected to rather than
+	// in the watched namespaces, so every namespace is watched for them
+	overrides := overrideStores{}
+	if opts.Overrides {
+		for _, k := range []Kind{KindSecrets, KindConfigMaps} {
+			client, err := newKind(k, clientset.CoreV1())
+			if err != nil {
+				return nil, err
+			}
+			indexer, controller := queue.CreateTransformingInformer(
+				client.ListWatch(v1.NamespaceAll),
+				client.ObjectType(),
+				rs.overrideHandler(client.Name()),
+				overrideIndexers,
+				keepOverrides,
+			)
+			overrides[client.Name()] = indexer
+			rs.overrideControllers = append(rs.overrideControllers, controller)
+		}
+	}
+
+	nsLister := corelisters.NewNamespaceLister(nsIndexer)
+	for _, r := range rs.reflectors {
+		r.nsLister = nsLister
+		r.overrides = overrides
+		synced := append([]cache.Controller{nsController}, rs.overrideControllers...)
+		synced = append(synced, r.controllers...)
+		r.hasSynced = func() bool {
+			for _, controller := range synced {
+				if !controller.HasSynced() {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return rs, nil
 }
 
 func (r *reflector) next() bool {
@@ -103,56 +306,164 @@ func (r *reflector) process(key string) error {
 	defer cancel()
 
 	// In the implementation of the cache, the returned error of GetByKey is always nil
-	obj, exists, _ := r.indexer.GetByKey(key)
+	item, exists, _ := r.indexers.getByKey(key)
 
-	name, namespace := queue.ParseWorkQueueKey(key)
+	namespace, name := queue.ParseWorkQueueKey(key)
 	ctxLogger := r.logger.With().
 		Str("rootNamespace", namespace).
-		Str("secret", name).Logger()
+		Str("object", name).Logger()
 
-	// Secret was deleted so we have to reconstruct the object in case cascadeDelete is set.
+	// Object was deleted so we have to reconstruct the object in case cascadeDelete is set.
 	if !exists {
+		// the data of deleted members is removed from their aggregate
+		if err := r.updateAggregates(ctx, ctxLogger, key, ""); err != nil {
+			return err
+		}
 		if !r.cascadeDelete {
-			ctxLogger.Info().Msg("secret deleted and `cascadeDelete` not set, not attempting to delete reflected secrets")
+			ctxLogger.Info().Msg("object deleted and `cascadeDelete` not set, not attempting to delete reflections")
 			return nil
 		}
-		namespaces, err := findExistingSecretNamespaces(ctx, r.core, name)
-		if err != nil {
-			return errors.Wrap(err, "unable to find namespaces secret existed in")
+		// config maps projected from a secret are deleted along with it
+		for _, client := range withProjections(r.kind) {
+			existing, err := findReflections(ctx, client, name, namespace)
+			if err != nil {
+				return errors.Wrapf(err, "unable to find namespaces %s existed in", client.Name())
+			}
+
+			if err := cascadeDelete(
+				ctx,
+				ctxLogger,
+				client,
+				existing,
+				r.reflectConcurrency); err != nil {
+				return err
+			}
 		}
-
-		return cascadeDelete(
-			ctx,
-			ctxLogger,
-			r.core,
-			name,
-			namespaces,
-			r.reflectConcurrency)
+		return nil
 	}
 
-	sec, ok := obj.(*v1.Secret)
+	cached, ok := item.(object)
 	if !ok {
-		return errors.New("could not convert object to secret")
+		return errors.Errorf("could not convert object to %s", r.kind.Name())
+	}
+	// the reflection annotations are stripped while reflecting, which must not
+	// leak into the cache as the namespace handler reads them from there
+	obj, ok := cached.DeepCopyObject().(object)
+	if !ok {
+		return errors.Errorf("could not copy %s", r.kind.Name())
 	}
 
-	// fetch the secret object's annotations
-	if shouldReflect, ok := sec.Annotations[annotations.ReflectAnnotation]; !ok || shouldReflect != "true" {
+	// overrides are layered on the reflections of the object they override,
+	// rather than reflected on their own
+	override, err := annotations.OverrideOf(obj.GetAnnotations())
+	if err != nil {
+		return errors.Wrap(err, "unable to parse override")
+	}
+	if override != "" {
+		ctxLogger.Debug().Str("overrideOf", override).Msg("object is an override, not reflecting it")
 		return nil
 	}
 
-	namespaces, err := annotations.ParseOrFetchNamespaces(
-		ctx, r.core, sec.Annotations)
+	// members of an aggregate are reflected as part of it, rather than on their own
+	aggregate, err := r.aggregateOf(obj)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse aggregate")
+	}
+	if err := r.updateAggregates(ctx, ctxLogger, key, aggregate); err != nil || aggregate != "" {
+		return err
+	}
+
+	pulling, err := pullingNamespaces(ctxLogger, r.nsLister, obj, r.excludeNamespaces)
 	if err != nil {
-		return errors.Wrap(err, "unable to parse namespaces")
+		return errors.Wrap(err, "unable to find pulling namespaces")
 	}
 
-	return reflectToNamespaces(
+	// fetch the object's annotations
+	shouldReflect := annotations.ShouldReflect(obj.GetAnnotations())
+	if !shouldReflect && len(pulling) == 0 {
+		// the config maps projected from a secret are unreflected along with it
+		for _, client := range withProjections(r.kind) {
+			if err := unreflect(
+				ctx,
+				ctxLogger,
+				client,
+				r.unreflectPolicy,
+				name,
+				namespace,
+				r.reflectConcurrency); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// sensitive types of secrets must never be broadcast to other namespaces,
+	// nor may secrets be converted to them
+	targetType, err := annotations.TargetType(obj.GetAnnotations())
+	if err != nil {
+		return errors.Wrap(err, "unable to parse target type")
+	}
+	if !r.secretTypes.permits(obj) || (targetType != "" && !r.secretTypes.permitsType(targetType)) {
+		denySecretType(ctxLogger, r.kind, obj)
+		return nil
+	}
+	// the metadata which must not be carried over is removed before
+	// hashing, which only covers the reflected payload, so that changes
+	// to it don't update the reflections
+	r.metadata.sanitize(obj)
+
+	namespaces := []string{}
+	if shouldReflect {
+		namespaces, err = annotations.ParseOrFetchNamespaces(
+			ctx, r.core, obj.GetAnnotations(), r.excludeNamespaces)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse namespaces")
+		}
+	}
+	namespaces = mergeNamespaces(namespaces, pulling)
+
+	// the target names are parsed before the annotations are stripped while reflecting
+	targetNames, err := annotations.ParseTargetNames(obj.GetAnnotations())
+	if err != nil {
+		return errors.Wrap(err, "unable to parse target names")
+	}
+	prune := annotations.ShouldPrune(obj.GetAnnotations(), r.prune)
+	projects := len(annotations.ProjectedKeys(obj.GetAnnotations())) > 0
+	if err := reflectToNamespaces(
 		ctx,
 		ctxLogger,
-		r.core,
-		sec,
+		r.kind,
+		r.recorder,
+		obj,
 		namespaces,
-		r.reflectConcurrency)
+		r.overrides.overridesOf(r.kind, key),
+		r.nsLister,
+		r.requireConsent,
+		r.recreateImmutable,
+		r.reflectConcurrency,
+	); err != nil || !prune {
+		return err
+	}
+
+	// config maps projected from a secret are pruned along with it
+	for _, client := range withProjections(r.kind) {
+		targeted := namespaces
+		// and are stale everywhere once the secret stops projecting keys
+		if isProjectionKind(client) && !projects {
+			targeted = nil
+		}
+		if err := pruneReflections(
+			ctx,
+			ctxLogger,
+			client,
+			obj,
+			targeted,
+			targetNames,
+			r.reflectConcurrency); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // handleErr checks if an error happened and makes sure we will retry later.
@@ -169,7 +480,7 @@ func (r *reflector) handleErr(err error, key interface{}) {
 	requeues := r.queue.NumRequeues(key)
 	if requeues < r.retries {
 		r.logger.Error().
-			Str("secret", key.(string)).
+			Str("object", key.(string)).
 			Err(err).
 			Msg("reflection failed; requeueing")
 
@@ -183,10 +494,10 @@ func (r *reflector) handleErr(err error, key interface{}) {
 	// Report to an external entity that, even after several retries, we could not successfully process this key
 	runtime.HandleError(err)
 	r.logger.Error().
-		Str("secret", key.(string)).
+		Str("object", key.(string)).
 		Int("requeues", requeues).
 		Err(err).
-		Msg("Dropping secret out of the queue")
+		Msg("Dropping object out of the queue")
 }
 
 func (r *reflector) Start(ctx context.Context) error {
@@ -198,8 +509,10 @@ func (r *reflector) Start(ctx context.Context) error {
 	// Let the workers stop when we are done
 	defer r.queue.ShutDown()
 
-	r.logger.Info().Msg("Spinning off controller")
-	go r.controller.Run(ctx.Done())
+	r.logger.Info().Msg("Spinning off controllers")
+	for _, controller := range r.controllers {
+		go controller.Run(ctx.Done())
+	}
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
 	r.logger.Info().Msg("Syncing cache before starting controller loop")
@@ -229,3 +542,37 @@ func (r *reflector) worker() {
 	for r.next() {
 	}
 }
+
+// Start runs the namespace informer and the reflector of every kind
+// until the context is cancelled or one of the reflectors stops
+func (rs *reflectors) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rs.broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: rs.events})
+	defer rs.broadcaster.Shutdown()
+
+	rs.logger.Info().Msg("Spinning off namespace controller")
+	go rs.nsController.Run(ctx.Done())
+	for _, controller := range rs.overrideControllers {
+		go controller.Run(ctx.Done())
+	}
+
+	errChan := make(chan error, len(rs.reflectors))
+	for _, r := range rs.reflectors {
+		kindReflector := r
+		go func() {
+			// stop every reflector if any of them stops
+			defer cancel()
+			errChan <- kindReflector.Start(ctx)
+		}()
+	}
+
+	var err error
+	for range rs.reflectors {
+		if rErr := <-errChan; rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+	return err
+}
@@ -12,14 +12,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/havulv/reflector/pkg/annotations"
+	"github.com/havulv/reflector/pkg/queue"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	fcache "k8s.io/client-go/tools/cache/testing"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -52,24 +56,146 @@ func TestNewReflector(t *testing.T) {
 			r, err := NewReflector(
 				zerolog.New(bytes.NewBuffer([]byte{})),
 				fake.NewSimpleClientset(),
-				test.rCon,
-				test.wCon,
-				12,
-				false,
-				"namespace",
+				Options{
+					Namespaces:         []string{"namespace"},
+					ReflectConcurrency: test.rCon,
+					WorkerConcurrency:  test.wCon,
+					Retries:            12,
+				},
 			)
 			assert.Nil(t, err)
 			if test.rCon < 1 {
-				assert.Equal(t, r.(*reflector).reflectConcurrency, 1)
+				assert.Equal(t, r.(*reflectors).reflectors[0].reflectConcurrency, 1)
 				return
 			}
 
 			if test.wCon < 1 {
-				assert.Equal(t, r.(*reflector).workerConcurrency, 1)
+				assert.Equal(t, r.(*reflectors).reflectors[0].workerConcurrency, 1)
 				return
 			}
 		})
 	}
+
+	t.Run("watches each source namespace", func(t *testing.T) {
+		t.Parallel()
+		r, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				Namespaces: []string{"hub-a", "hub-b", "hub-c"},
+			},
+		)
+		require.Nil(t, err)
+		assert.Len(t, r.(*reflectors).reflectors[0].controllers, 3)
+		assert.Len(t, r.(*reflectors).reflectors[0].indexers, 3)
+	})
+
+	t.Run("creates a reflector for each kind", func(t *testing.T) {
+		t.Parallel()
+		r, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				Kinds: []Kind{KindSecrets, KindConfigMaps, KindSecrets},
+			},
+		)
+		require.Nil(t, err)
+		rs := r.(*reflectors).reflectors
+		require.Len(t, rs, 2)
+		assert.Equal(t, "secret", rs[0].kind.Name())
+		assert.Equal(t, "configmap", rs[1].kind.Name())
+	})
+
+	t.Run("reflects secrets by default", func(t *testing.T) {
+		t.Parallel()
+		r, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{},
+		)
+		require.Nil(t, err)
+		rs := r.(*reflectors).reflectors
+		require.Len(t, rs, 1)
+		assert.Equal(t, "secret", rs[0].kind.Name())
+	})
+
+	t.Run("creates a reflector for each resource", func(t *testing.T) {
+		t.Parallel()
+		dynamicClient, _ := resourceClient()
+		r, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				Resources: []schema.GroupVersionResource{networkPolicies, networkPolicies},
+				Dynamic:   dynamicClient,
+			},
+		)
+		require.Nil(t, err)
+		rs := r.(*reflectors).reflectors
+		require.Len(t, rs, 2)
+		assert.Equal(t, "secret", rs[0].kind.Name())
+		assert.Equal(t, "networkpolicies.networking.k8s.io", rs[1].kind.Name())
+	})
+
+	t.Run("fails on resources without a dynamic client", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				Resources: []schema.GroupVersionResource{networkPolicies},
+			},
+		)
+		assert.True(t, errors.Is(err, ErrorNoDynamicClient))
+	})
+
+	t.Run("fails on invalid metadata patterns", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				DeniedLabels: []string{"team/["},
+			},
+		)
+		assert.True(t, errors.Is(err, ErrorInvalidMetadataPattern))
+	})
+
+	t.Run("fails on an invalid kind", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				Kinds: []Kind{"pods"},
+			},
+		)
+		assert.True(t, errors.Is(err, ErrorInvalidKind))
+	})
+
+	t.Run("fails on invalid excluded namespaces", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				ExcludeNamespaces: []string{"kube-system", "regex:("},
+			},
+		)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("fails on an invalid unreflect policy", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewReflector(
+			zerolog.New(bytes.NewBuffer([]byte{})),
+			fake.NewSimpleClientset(),
+			Options{
+				UnreflectPolicy: "keep",
+			},
+		)
+		assert.NotNil(t, err)
+	})
 }
 
 func TestNext(t *testing.T) {
@@ -103,10 +229,11 @@ func TestNext(t *testing.T) {
 				}, cache.Indexers{})
 
 			r := reflector{
-				ctx:        context.Background(),
-				queue:      queue,
-				indexer:    indexer,
-				controller: informer,
+				ctx:         context.Background(),
+				kind:        secretsClient(fake.NewSimpleClientset()),
+				queue:       queue,
+				indexers:    objectStores{v1.NamespaceAll: indexer},
+				controllers: []cache.Controller{informer},
 			}
 
 			if test.shutdown {
@@ -268,14 +395,16 @@ func TestProcess(t *testing.T) {
 					})
 			}
 
-			buf := bytes.NewBuffer([]byte{})
+			buf := &lockedBuffer{}
 			r := reflector{
 				ctx:           context.Background(),
 				logger:        zerolog.New(buf),
 				core:          client.CoreV1(),
+				kind:          secretsClient(client),
 				queue:         queue,
-				indexer:       indexer,
-				controller:    informer,
+				indexers:      objectStores{v1.NamespaceAll: indexer},
+				controllers:   []cache.Controller{informer},
+				nsLister:      namespaceLister(t),
 				cascadeDelete: test.cascadeDelete,
 			}
 
@@ -301,6 +430,251 @@ func TestProcess(t *testing.T) {
 	}
 }
 
+func TestProcessPrune(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		prune      bool
+		annotation string
+		remaining  []string
+	}{
+		{
+			"does not prune by default",
+			false,
+			"",
+			[]string{"ns1", "ns2", "stale"},
+		},
+		{
+			"prunes when enabled",
+			true,
+			"",
+			[]string{"ns1", "ns2"},
+		},
+		{
+			"prunes when the secret opts in",
+			false,
+			"true",
+			[]string{"ns1", "ns2"},
+		},
+		{
+			"does not prune when the secret opts out",
+			true,
+			"false",
+			[]string{"ns1", "ns2", "stale"},
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			sec := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: "thing",
+					Annotations: map[string]string{
+						annotations.ReflectAnnotation:   "true",
+						annotations.NamespaceAnnotation: "ns1,ns2",
+					},
+				},
+			}
+			if test.annotation != "" {
+				sec.Annotations[annotations.PruneAnnotation] = test.annotation
+			}
+
+			stale := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: "stale",
+					Labels:    reflectionOf("thing", "secret"),
+					Annotations: map[string]string{
+						annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+						annotations.ReflectedFromAnnotation:   "thing",
+						annotations.ReflectionHashAnnotation:  "old-hash",
+					},
+				},
+			}
+			client := fake.NewSimpleClientset(sec, stale)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.Nil(t, indexer.Add(sec))
+
+			r := reflector{
+				ctx:                ctx,
+				logger:             zerolog.Nop(),
+				core:               client.CoreV1(),
+				kind:               secretsClient(client),
+				indexers:           objectStores{v1.NamespaceAll: indexer},
+				nsLister:           namespaceLister(t),
+				prune:              test.prune,
+				reflectConcurrency: 1,
+			}
+			require.Nil(t, r.process("thing/secret"))
+
+			remaining, err := findReflections(ctx, secretsClient(client), "secret", "thing")
+			require.Nil(t, err)
+			assert.ElementsMatch(t, test.remaining, remaining.namespaces())
+		})
+	}
+}
+
+func TestProcessUnreflect(t *testing.T) {
+	ctx := context.Background()
+	sec := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "thing",
+			Annotations: map[string]string{
+				annotations.ReflectAnnotation:   "true",
+				annotations.NamespaceAnnotation: "ns1",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset()
+	indexer := storeOf(t, sec)
+	// every reflector starts out without knowing what was reflected before
+	newReflector := func() *reflector {
+		return &reflector{
+			ctx:                ctx,
+			logger:             zerolog.Nop(),
+			core:               client.CoreV1(),
+			kind:               secretsClient(client),
+			indexers:           objectStores{v1.NamespaceAll: indexer},
+			nsLister:           namespaceLister(t),
+			unreflectPolicy:    UnreflectDelete,
+			reflectConcurrency: 1,
+		}
+	}
+
+	require.Nil(t, newReflector().process("thing/secret"))
+	_, err := client.CoreV1().Secrets("ns1").Get(ctx, "secret", metav1.GetOptions{})
+	require.Nil(t, err)
+
+	unreflected := sec.DeepCopy()
+	delete(unreflected.Annotations, annotations.ReflectAnnotation)
+	require.Nil(t, indexer.Update(unreflected))
+	client.ClearActions()
+	require.Nil(t, newReflector().process("thing/secret"))
+	_, err = client.CoreV1().Secrets("ns1").Get(ctx, "secret", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// reflections, and the config maps projected from them, are only listed by their label
+	listed := 0
+	for _, action := range client.Actions() {
+		if list, ok := action.(clienttesting.ListAction); ok && action.GetResource().Resource != "serviceaccounts" {
+			listed++
+			assert.Equal(t,
+				annotations.ReflectionOfSelector("thing", "secret"),
+				list.GetListRestrictions().Labels.String())
+		}
+	}
+	assert.Equal(t, 2, listed)
+}
+
+func TestProcessCascadeDeleteParsesKey(t *testing.T) {
+	ctx := context.Background()
+	reflection := func(ns string, from string, name string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels:    reflectionOf(from, name),
+				Annotations: map[string]string{
+					annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+					annotations.ReflectedFromAnnotation:   from,
+				},
+			},
+		}
+	}
+	// a reflection of thing/secret, and of secret/thing which has the
+	// namespace and name of the key the other way around
+	client := fake.NewSimpleClientset(
+		reflection("ns1", "thing", "secret"),
+		reflection("ns2", "secret", "thing"),
+	)
+	r := reflector{
+		ctx:                ctx,
+		logger:             zerolog.Nop(),
+		core:               client.CoreV1(),
+		kind:               secretsClient(client),
+		indexers:           objectStores{v1.NamespaceAll: storeOf(t)},
+		nsLister:           namespaceLister(t),
+		cascadeDelete:      true,
+		reflectConcurrency: 1,
+	}
+	require.Nil(t, r.process("thing/secret"))
+
+	_, err := client.CoreV1().Secrets("ns1").Get(ctx, "secret", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = client.CoreV1().Secrets("ns2").Get(ctx, "thing", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestProcessNamespaces(t *testing.T) {
+	tests := []struct {
+		descrip   string
+		key       string
+		reflected bool
+	}{
+		{
+			"reflects a secret from the first watched namespace",
+			"hub-a/secret",
+			true,
+		},
+		{
+			"reflects a secret from another watched namespace",
+			"hub-b/secret",
+			true,
+		},
+		{
+			"ignores a secret from an unwatched namespace",
+			"other/secret",
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			source := func(ns string) *v1.Secret {
+				return &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: ns,
+						Annotations: map[string]string{
+							annotations.ReflectAnnotation:   "true",
+							annotations.NamespaceAnnotation: "target-" + ns,
+						},
+					},
+				}
+			}
+			hubA := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.Nil(t, hubA.Add(source("hub-a")))
+			hubB := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.Nil(t, hubB.Add(source("hub-b")))
+
+			client := fake.NewSimpleClientset()
+			r := reflector{
+				ctx:    ctx,
+				logger: zerolog.Nop(),
+				core:   client.CoreV1(),
+				kind:   secretsClient(client),
+				indexers: objectStores{
+					"hub-a": hubA,
+					"hub-b": hubB,
+				},
+				nsLister:           namespaceLister(t),
+				reflectConcurrency: 1,
+			}
+			require.Nil(t, r.process(test.key))
+
+			ns, _ := queue.ParseWorkQueueKey(test.key)
+			_, err := client.CoreV1().Secrets("target-"+ns).Get(
+				ctx, "secret", metav1.GetOptions{})
+			assert.Equal(t, test.reflected, err == nil)
+		})
+	}
+}
+
 func TestHandleErr(t *testing.T) {
 	tests := []struct {
 		descrip string
@@ -386,7 +760,7 @@ func TestStart(t *testing.T) {
 		test := l
 		t.Run(test.descrip, func(t *testing.T) {
 			t.Parallel()
-			buf := bytes.NewBuffer([]byte{})
+			buf := &lockedBuffer{}
 			limiter := workqueue.NewItemExponentialFailureRateLimiter(
 				1*time.Millisecond, 1*time.Millisecond)
 			queue := workqueue.NewRateLimitingQueue(limiter)
@@ -398,12 +772,11 @@ func TestStart(t *testing.T) {
 					UpdateFunc: func(old interface{}, new interface{}) {},
 					DeleteFunc: func(obj interface{}) {},
 				}, cache.Indexers{})
-
 			r := &reflector{
 				logger:            zerolog.New(buf),
 				queue:             queue,
-				indexer:           indexer,
-				controller:        informer,
+				indexers:          objectStores{v1.NamespaceAll: indexer},
+				controllers:       []cache.Controller{informer},
 				hasSynced:         func() bool { return true },
 				workerConcurrency: 1,
 			}
@@ -458,6 +831,77 @@ func TestStart(t *testing.T) {
 	}
 }
 
+func TestReflectorsStart(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		failSync bool
+	}{
+		{
+			"runs every reflector until context cancellation",
+			false,
+		},
+		{
+			"stops every reflector when one fails",
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			buf := &lockedBuffer{}
+			newReflector := func(synced bool) *reflector {
+				limiter := workqueue.NewItemExponentialFailureRateLimiter(
+					1*time.Millisecond, 1*time.Millisecond)
+				return &reflector{
+					logger:            zerolog.New(buf),
+					queue:             workqueue.NewRateLimitingQueue(limiter),
+					hasSynced:         func() bool { return synced },
+					workerConcurrency: 1,
+				}
+			}
+			_, nsInformer := cache.NewIndexerInformer(
+				fcache.NewFakeControllerSource(), &v1.Namespace{}, 0,
+				cache.ResourceEventHandlerFuncs{}, cache.Indexers{})
+
+			rs := &reflectors{
+				logger:       zerolog.New(buf),
+				reflectors:   []*reflector{newReflector(true), newReflector(!test.failSync)},
+				nsController: nsInformer,
+				events:       fake.NewSimpleClientset().CoreV1().Events(v1.NamespaceAll),
+				broadcaster:  record.NewBroadcaster(),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.failSync {
+				// a cancelled context fails the cache sync of the failing reflector
+				cancel()
+			} else {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			defer cancel()
+
+			errChan := make(chan error)
+			go func() {
+				errChan <- rs.Start(ctx)
+			}()
+
+			var err error
+			select {
+			case err = <-errChan:
+			case <-time.After(1 * time.Second):
+				t.Log(buf.String())
+				t.Fatal("timed out waiting for the reflectors to stop")
+			}
+			if test.failSync {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
 func TestWorker(t *testing.T) {
 	tests := []struct {
 		descrip string
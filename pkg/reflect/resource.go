@@ -0,0 +1,127 @@
+package reflect
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	// ErrorInvalidResource is used when a resource can't be parsed
+	ErrorInvalidResource = errors.New("invalid resource")
+	// ErrorNoDynamicClient is used when resources are reflected without
+	// a dynamic client to reflect them with
+	ErrorNoDynamicClient = errors.New("a dynamic client is required to reflect resources")
+)
+
+// ParseResource parses a resource in the form group/version/resource,
+// or version/resource for the core group, e.g. networking.k8s.io/v1/networkpolicies
+func ParseResource(resource string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(resource, "/")
+	for _, part := range parts {
+		if part == "" {
+			return schema.GroupVersionResource{}, errors.Wrapf(ErrorInvalidResource, "%q", resource)
+		}
+	}
+
+	switch len(parts) {
+	case 2:
+		return schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}, nil
+	case 3:
+		return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+	default:
+		return schema.GroupVersionResource{}, errors.Wrapf(ErrorInvalidResource, "%q", resource)
+	}
+}
+
+// stripStatus removes the status of unstructured objects, which is written by
+// the controllers of the resource in each namespace rather than reflected
+func stripStatus(obj object) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		unstructured.RemoveNestedField(u.Object, "status")
+	}
+}
+
+// resourceKind reflects any namespaced resource as unstructured objects
+type resourceKind struct {
+	client   dynamic.Interface
+	resource schema.GroupVersionResource
+}
+
+func (k resourceKind) Name() string {
+	return k.resource.GroupResource().String()
+}
+
+func (k resourceKind) Objects(namespace string) objectInterface {
+	return resourceObjects{client: k.client.Resource(k.resource).Namespace(namespace)}
+}
+
+func (k resourceKind) List(
+	ctx context.Context,
+	namespace string,
+	opts metav1.ListOptions,
+) ([]object, error) {
+	found, err := k.client.Resource(k.resource).Namespace(namespace).List(ctx, opts)
+	if err != nil {
+		return []object{}, err
+	}
+	objs := []object{}
+	for i := range found.Items {
+		objs = append(objs, &found.Items[i])
+	}
+	return objs, nil
+}
+
+func (k resourceKind) ListWatch(namespace string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return k.client.Resource(k.resource).Namespace(namespace).List(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return k.client.Resource(k.resource).Namespace(namespace).Watch(context.Background(), opts)
+		},
+	}
+}
+
+func (k resourceKind) ObjectType() runtime.Object {
+	return &unstructured.Unstructured{}
+}
+
+// resourceObjects operates on the objects of a resource in a namespace
+type resourceObjects struct {
+	client dynamic.ResourceInterface
+}
+
+func (o resourceObjects) Get(ctx context.Context, name string) (object, error) {
+	return o.client.Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o resourceObjects) Create(ctx context.Context, obj object) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return errors.New("could not convert object to unstructured")
+	}
+	_, err := o.client.Create(ctx, u, metav1.CreateOptions{})
+	return err
+}
+
+func (o resourceObjects) Update(ctx context.Context, obj object) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return errors.New("could not convert object to unstructured")
+	}
+	_, err := o.client.Update(ctx, u, metav1.UpdateOptions{})
+	return err
+}
+
+func (o resourceObjects) Delete(ctx context.Context, name string) error {
+	return o.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
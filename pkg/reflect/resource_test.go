@@ -0,0 +1,260 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+var networkPolicies = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "networkpolicies",
+}
+
+func resourceClient(objs ...runtime.Object) (*dynamicfake.FakeDynamicClient, kind) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{networkPolicies: "NetworkPolicyList"},
+		objs...)
+	return client, resourceKind{client: client, resource: networkPolicies}
+}
+
+func networkPolicy(namespace string, objAnnotations map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("networking.k8s.io/v1")
+	u.SetKind("NetworkPolicy")
+	u.SetName("thing")
+	u.SetNamespace(namespace)
+	u.SetAnnotations(objAnnotations)
+	u.Object["spec"] = map[string]interface{}{
+		"podSelector": map[string]interface{}{},
+	}
+	return u
+}
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		descrip  string
+		resource string
+		expected schema.GroupVersionResource
+		err      error
+	}{
+		{
+			"parses a resource with a group",
+			"networking.k8s.io/v1/networkpolicies",
+			networkPolicies,
+			nil,
+		},
+		{
+			"parses a resource in the core group",
+			"v1/services",
+			schema.GroupVersionResource{Version: "v1", Resource: "services"},
+			nil,
+		},
+		{
+			"fails without a version",
+			"networkpolicies",
+			schema.GroupVersionResource{},
+			ErrorInvalidResource,
+		},
+		{
+			"fails on empty parts",
+			"networking.k8s.io//networkpolicies",
+			schema.GroupVersionResource{},
+			ErrorInvalidResource,
+		},
+		{
+			"fails on too many parts",
+			"a/b/c/d",
+			schema.GroupVersionResource{},
+			ErrorInvalidResource,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			resource, err := ParseResource(test.resource)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, test.expected, resource)
+		})
+	}
+}
+
+func TestHashObject(t *testing.T) {
+	t.Run("hashes typed objects", func(t *testing.T) {
+		t.Parallel()
+		hash, err := hashObject(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "thing"}})
+		require.Nil(t, err)
+		other, err := hashObject(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other"}})
+		require.Nil(t, err)
+		assert.NotEqual(t, hash, other)
+	})
+
+	t.Run("ignores server metadata", func(t *testing.T) {
+		t.Parallel()
+		sec := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "thing", ResourceVersion: "1"},
+			Data:       map[string][]byte{"key": []byte("value")},
+		}
+		hash, err := hashObject(sec)
+		require.Nil(t, err)
+		changed := sec.DeepCopy()
+		changed.ResourceVersion = "2"
+		changed.UID = "uid"
+		changed.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}
+		again, err := hashObject(changed)
+		require.Nil(t, err)
+		assert.Equal(t, hash, again)
+
+		changed.Data["key"] = []byte("other")
+		other, err := hashObject(changed)
+		require.Nil(t, err)
+		assert.NotEqual(t, hash, other)
+	})
+
+	t.Run("hashes unstructured objects", func(t *testing.T) {
+		t.Parallel()
+		obj := networkPolicy("source", nil)
+		hash, err := hashObject(obj)
+		require.Nil(t, err)
+		again, err := hashObject(obj.DeepCopy())
+		require.Nil(t, err)
+		assert.Equal(t, hash, again)
+
+		obj.SetResourceVersion("2")
+		obj.Object["status"] = map[string]interface{}{"ready": true}
+		again, err = hashObject(obj)
+		require.Nil(t, err)
+		assert.Equal(t, hash, again)
+
+		obj.Object["spec"] = map[string]interface{}{}
+		changed, err := hashObject(obj)
+		require.Nil(t, err)
+		assert.NotEqual(t, hash, changed)
+	})
+}
+
+func TestReflectResource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := networkPolicy("source", map[string]string{
+		annotations.ReflectAnnotation: "true",
+	})
+	client, resources := resourceClient(source)
+	assert.Equal(t, "networkpolicies.networking.k8s.io", resources.Name())
+
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		resources,
+		nil,
+		source.DeepCopy(),
+		[]string{"ns1", "ns2"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		2))
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		reflected, err := client.Resource(networkPolicies).Namespace(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, source.Object["spec"], reflected.Object["spec"])
+		assert.Equal(t, annotations.ReflectionOwned, reflected.GetAnnotations()[annotations.ReflectionOwnerAnnotation])
+		assert.Equal(t, "source", reflected.GetAnnotations()[annotations.ReflectedFromAnnotation])
+		assert.NotContains(t, reflected.GetAnnotations(), annotations.ReflectAnnotation)
+	}
+
+	existing, err := findReflections(ctx, resources, "thing", "source")
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, existing.namespaces())
+
+	require.Nil(t, cascadeDelete(
+		ctx,
+		zerolog.Nop(),
+		resources,
+		existing,
+		2))
+	for _, ns := range existing.namespaces() {
+		_, err := client.Resource(networkPolicies).Namespace(ns).Get(ctx, "thing", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	}
+	_, err = client.Resource(networkPolicies).Namespace("source").Get(ctx, "thing", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestReflectResourceUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := networkPolicy("source", map[string]string{
+		annotations.ReflectAnnotation: "true",
+	})
+	source.Object["status"] = map[string]interface{}{"conditions": []interface{}{}}
+	reflection := networkPolicy("ns1", map[string]string{
+		annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+		annotations.ReflectionHashAnnotation:  "old-hash",
+		annotations.ReflectedFromAnnotation:   "source",
+	})
+	reflection.SetResourceVersion("42")
+	client, resources := resourceClient(source, reflection)
+
+	var updated *unstructured.Unstructured
+	client.PrependReactor("update", "networkpolicies",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			obj, ok := action.(clienttesting.UpdateAction).GetObject().(*unstructured.Unstructured)
+			require.True(t, ok)
+			updated = obj
+			return false, nil, nil
+		})
+
+	reflectSource := func(obj *unstructured.Unstructured) {
+		require.Nil(t, reflectToNamespaces(
+			ctx,
+			zerolog.Nop(),
+			resources,
+			nil,
+			obj,
+			[]string{"ns1"},
+			nil,
+			namespaceLister(t),
+			false,
+			false,
+			1))
+	}
+	reflectSource(source.DeepCopy())
+	require.NotNil(t, updated)
+	// custom resources are only updated with the version of the reflection
+	assert.Equal(t, "42", updated.GetResourceVersion())
+	assert.NotContains(t, updated.Object, "status")
+
+	// the status doesn't change the hash
+	hash := updated.GetAnnotations()[annotations.ReflectionHashAnnotation]
+	changed := source.DeepCopy()
+	changed.Object["status"] = map[string]interface{}{"observedGeneration": int64(2)}
+	reflectSource(changed)
+	reflected, err := client.Resource(networkPolicies).Namespace("ns1").Get(ctx, "thing", metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, hash, reflected.GetAnnotations()[annotations.ReflectionHashAnnotation])
+}
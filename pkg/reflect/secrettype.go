@@ -0,0 +1,179 @@
+package reflect
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultDeniedSecretTypes are the secret types which are never reflected
+// unless the denied secret types are configured, as they hold credentials
+// for the cluster itself
+var DefaultDeniedSecretTypes = []string{
+	string(v1.SecretTypeServiceAccountToken),
+	string(v1.SecretTypeBootstrapToken),
+}
+
+// secretTypePolicy determines which types of secrets may be reflected
+type secretTypePolicy struct {
+	allowed map[v1.SecretType]struct{}
+	denied  map[v1.SecretType]struct{}
+}
+
+// newSecretTypePolicy creates the policy from the allowed and denied secret
+// types. Every type is allowed if there are no allowed types, and the
+// default denied types are used if the denied types are nil.
+func newSecretTypePolicy(allowed []string, denied []string) secretTypePolicy {
+	if denied == nil {
+		denied = DefaultDeniedSecretTypes
+	}
+
+	policy := secretTypePolicy{
+		allowed: map[v1.SecretType]struct{}{},
+		denied:  map[v1.SecretType]struct{}{},
+	}
+	for _, t := range allowed {
+		policy.allowed[v1.SecretType(t)] = struct{}{}
+	}
+	for _, t := range denied {
+		policy.denied[v1.SecretType(t)] = struct{}{}
+	}
+	return policy
+}
+
+// permits checks if the object may be reflected. Denied types win over
+// allowed types, and objects other than secrets are always permitted.
+func (p secretTypePolicy) permits(obj object) bool {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return true
+	}
+
+	return p.permitsType(secret.Type)
+}
+
+// permitsType checks if secrets of the type may be reflected
+func (p secretTypePolicy) permitsType(secretType v1.SecretType) bool {
+	// secrets without a type are created as opaque secrets
+	if secretType == "" {
+		secretType = v1.SecretTypeOpaque
+	}
+	if _, ok := p.denied[secretType]; ok {
+		return false
+	}
+	if len(p.allowed) == 0 {
+		return true
+	}
+	_, ok := p.allowed[secretType]
+	return ok
+}
+
+// denySecretType logs and counts an object which is not reflected
+// because its type is not permitted
+func denySecretType(
+	logger zerolog.Logger,
+	client kind,
+	obj object,
+) {
+	secretType := ""
+	if secret, ok := obj.(*v1.Secret); ok {
+		secretType = string(secret.Type)
+	}
+	logger.Warn().
+		Str("type", secretType).
+		Msgf("%s type is not allowed to be reflected, skipping", client.Name())
+	reflectorDenials.WithLabelValues(client.Name(), obj.GetName(), secretType).Inc()
+}
+
+// ErrorMissingTypeKeys is used when a secret lacks the keys its type requires
+var ErrorMissingTypeKeys = errors.New("missing keys required by the secret type")
+
+// typeKeys are the keys which the types of secrets require, as groups of
+// keys of which at least one must be present
+var typeKeys = map[v1.SecretType][][]string{
+	v1.SecretTypeTLS:              {{v1.TLSCertKey}, {v1.TLSPrivateKeyKey}},
+	v1.SecretTypeBasicAuth:        {{v1.BasicAuthUsernameKey, v1.BasicAuthPasswordKey}},
+	v1.SecretTypeSSHAuth:          {{v1.SSHAuthPrivateKey}},
+	v1.SecretTypeDockercfg:        {{v1.DockerConfigKey}},
+	v1.SecretTypeDockerConfigJson: {{v1.DockerConfigJsonKey}},
+}
+
+// convertType converts a secret to the type. Objects other than secrets
+// are left as they are.
+func convertType(obj object, secretType v1.SecretType) {
+	if secret, ok := obj.(*v1.Secret); ok && secretType != "" {
+		secret.Type = secretType
+	}
+}
+
+// validateType checks that a secret holds the keys its type requires
+func validateType(obj object) error {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return nil
+	}
+	for _, keys := range typeKeys[secret.Type] {
+		found := false
+		for _, key := range keys {
+			_, inData := secret.Data[key]
+			_, inStringData := secret.StringData[key]
+			found = found || inData || inStringData
+		}
+		if found {
+			continue
+		}
+		if len(keys) == 1 {
+			return errors.Wrapf(ErrorMissingTypeKeys, "%s secrets require the key %q", secret.Type, keys[0])
+		}
+		return errors.Wrapf(
+			ErrorMissingTypeKeys, "%s secrets require one of the keys %q",
+			secret.Type, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// changesType checks if the reflection of a secret has a different type than
+// the secret, which can't be updated as the type of a secret is immutable
+func changesType(reflected object, obj object) bool {
+	reflectedSecret, ok := reflected.(*v1.Secret)
+	if !ok {
+		return false
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return false
+	}
+	return typeOf(reflectedSecret) != typeOf(secret)
+}
+
+// typeOf is the type of a secret, where secrets without a type are
+// created as opaque secrets
+func typeOf(secret *v1.Secret) v1.SecretType {
+	if secret.Type == "" {
+		return v1.SecretTypeOpaque
+	}
+	return secret.Type
+}
+
+// deleteChangedType deletes a reflection whose type changed, so that it can
+// be created again with the new type. Unlike immutable reflections, they are
+// always recreated, as they would otherwise never get the new type. The
+// namespace is skipped if the reflector may not delete the reflection.
+func deleteChangedType(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	name string,
+	namespace string,
+) error {
+	logger.Info().Msgf("recreating %s with a different type", client.Name())
+	err := client.Objects(namespace).Delete(ctx, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error while deleting %s of a different type", client.Name())
+	}
+	return nil
+}
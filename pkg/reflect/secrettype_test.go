@@ -0,0 +1,310 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestSecretTypePolicyPermits(t *testing.T) {
+	tests := []struct {
+		descrip string
+		allowed []string
+		denied  []string
+		obj     object
+		permits bool
+	}{
+		{
+			"denies service account tokens by default",
+			nil,
+			nil,
+			&v1.Secret{Type: v1.SecretTypeServiceAccountToken},
+			false,
+		},
+		{
+			"denies bootstrap tokens by default",
+			nil,
+			nil,
+			&v1.Secret{Type: v1.SecretTypeBootstrapToken},
+			false,
+		},
+		{
+			"permits other types by default",
+			nil,
+			nil,
+			&v1.Secret{Type: v1.SecretTypeDockerConfigJson},
+			true,
+		},
+		{
+			"permits service account tokens when the default is cleared",
+			nil,
+			[]string{},
+			&v1.Secret{Type: v1.SecretTypeServiceAccountToken},
+			true,
+		},
+		{
+			"permits only allowed types",
+			[]string{string(v1.SecretTypeTLS)},
+			nil,
+			&v1.Secret{Type: v1.SecretTypeDockerConfigJson},
+			false,
+		},
+		{
+			"permits an allowed type",
+			[]string{string(v1.SecretTypeTLS)},
+			nil,
+			&v1.Secret{Type: v1.SecretTypeTLS},
+			true,
+		},
+		{
+			"treats secrets without a type as opaque",
+			[]string{string(v1.SecretTypeOpaque)},
+			nil,
+			&v1.Secret{},
+			true,
+		},
+		{
+			"denied types win over allowed types",
+			[]string{string(v1.SecretTypeTLS)},
+			[]string{string(v1.SecretTypeTLS)},
+			&v1.Secret{Type: v1.SecretTypeTLS},
+			false,
+		},
+		{
+			"always permits objects other than secrets",
+			[]string{string(v1.SecretTypeTLS)},
+			nil,
+			&v1.ConfigMap{},
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			policy := newSecretTypePolicy(test.allowed, test.denied)
+			assert.Equal(t, test.permits, policy.permits(test.obj))
+		})
+	}
+}
+
+func TestProcessSecretTypes(t *testing.T) {
+	tests := []struct {
+		descrip    string
+		secretType v1.SecretType
+		targetType v1.SecretType
+		reflected  bool
+	}{
+		{
+			"reflects a permitted secret",
+			v1.SecretTypeDockerConfigJson,
+			"",
+			true,
+		},
+		{
+			"does not reflect a denied secret",
+			v1.SecretTypeServiceAccountToken,
+			"",
+			false,
+		},
+		{
+			"does not convert a secret to a denied type",
+			v1.SecretTypeOpaque,
+			v1.SecretTypeServiceAccountToken,
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			name := "typed-" + string(test.secretType)
+			indexer := storeOf(t, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "source",
+					Annotations: map[string]string{
+						annotations.ReflectAnnotation:   "true",
+						annotations.NamespaceAnnotation: "target",
+					},
+				},
+				Type: test.secretType,
+				Data: map[string][]byte{v1.DockerConfigJsonKey: []byte("{}")},
+			})
+			if test.targetType != "" {
+				sec, _, _ := indexer.GetByKey("source/" + name)
+				sec.(*v1.Secret).Annotations[annotations.TargetTypeAnnotation] = string(test.targetType)
+			}
+
+			client := fake.NewSimpleClientset()
+			r := reflector{
+				ctx:                ctx,
+				logger:             zerolog.Nop(),
+				core:               client.CoreV1(),
+				kind:               secretsClient(client),
+				indexers:           objectStores{v1.NamespaceAll: indexer},
+				nsLister:           namespaceLister(t),
+				secretTypes:        newSecretTypePolicy(nil, nil),
+				reflectConcurrency: 1,
+			}
+			require.Nil(t, r.process("source/"+name))
+
+			_, err := client.CoreV1().Secrets("target").Get(ctx, name, metav1.GetOptions{})
+			assert.Equal(t, test.reflected, err == nil)
+
+			m, err := reflectorDenials.GetMetricWithLabelValues("secret", name, string(test.secretType))
+			require.Nil(t, err)
+			metric := &dto.Metric{}
+			require.Nil(t, m.Write(metric))
+			denials := 1.0
+			if test.reflected {
+				denials = 0
+			}
+			assert.Equal(t, denials, metric.GetCounter().GetValue())
+		})
+	}
+}
+
+func TestValidateType(t *testing.T) {
+	tests := []struct {
+		descrip string
+		obj     object
+		err     bool
+	}{
+		{
+			"accepts a TLS secret with a certificate and key",
+			&v1.Secret{Type: v1.SecretTypeTLS, Data: map[string][]byte{"tls.crt": {}, "tls.key": {}}},
+			false,
+		},
+		{
+			"rejects a TLS secret without a key",
+			&v1.Secret{Type: v1.SecretTypeTLS, Data: map[string][]byte{"tls.crt": {}}},
+			true,
+		},
+		{
+			"accepts a basic auth secret with only a password",
+			&v1.Secret{Type: v1.SecretTypeBasicAuth, StringData: map[string]string{"password": "hunter2"}},
+			false,
+		},
+		{
+			"rejects a basic auth secret without a username or password",
+			&v1.Secret{Type: v1.SecretTypeBasicAuth, Data: map[string][]byte{"user": {}}},
+			true,
+		},
+		{
+			"accepts any keys of other types",
+			&v1.Secret{Type: "example.com/custom"},
+			false,
+		},
+		{
+			"accepts objects other than secrets",
+			&v1.ConfigMap{},
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			err := validateType(test.obj)
+			if test.err {
+				assert.ErrorIs(t, err, ErrorMissingTypeKeys)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestReflectTargetType(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	reflectSource := func(data map[string][]byte, targetType v1.SecretType) error {
+		return reflectToNamespaces(
+			ctx,
+			zerolog.Nop(),
+			secretsClient(client),
+			nil,
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "converted",
+					Namespace: "source",
+					Annotations: map[string]string{
+						annotations.KeyMapAnnotation:     "cert=tls.crt,key=tls.key",
+						annotations.TargetTypeAnnotation: string(targetType),
+					},
+				},
+				Type: v1.SecretTypeOpaque,
+				Data: data,
+			},
+			[]string{"target"},
+			nil,
+			namespaceLister(t),
+			false,
+			false,
+			1)
+	}
+	get := func() *v1.Secret {
+		sec, err := client.CoreV1().Secrets("target").Get(ctx, "converted", metav1.GetOptions{})
+		require.Nil(t, err)
+		return sec
+	}
+	skipped := func() float64 {
+		metric := &dto.Metric{}
+		require.Nil(t, reflectorReflections.WithLabelValues("skip", "secret", "converted", "false", "target").Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+	verbs := func() []string {
+		found := []string{}
+		for _, action := range client.Actions() {
+			found = append(found, action.GetVerb())
+		}
+		return found
+	}
+
+	// missing keys are refused before anything is reflected, and skipped
+	// rather than retried, as a retry won't add them
+	require.Nil(t, reflectSource(map[string][]byte{"cert": []byte("cert")}, v1.SecretTypeTLS))
+	assert.Equal(t, 1.0, skipped())
+	_, err := client.CoreV1().Secrets("target").Get(ctx, "converted", metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	require.Nil(t, reflectSource(map[string][]byte{"cert": []byte("cert"), "key": []byte("key")}, ""))
+	assert.Equal(t, v1.SecretTypeOpaque, get().Type)
+
+	// the type of a secret is immutable, so the reflection is recreated, even
+	// though immutable reflections aren't
+	client.ClearActions()
+	require.Nil(t, reflectSource(map[string][]byte{"cert": []byte("cert"), "key": []byte("key")}, v1.SecretTypeTLS))
+	assert.Equal(t, []string{"get", "delete", "create"}, verbs())
+	converted := get()
+	assert.Equal(t, v1.SecretTypeTLS, converted.Type)
+	assert.Equal(t, []string{"tls.crt", "tls.key"}, sortedKeys(converted.Data))
+	assert.NotContains(t, converted.Annotations, annotations.TargetTypeAnnotation)
+
+	// the namespace is skipped if the reflection may not be deleted
+	client.PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, apierrors.NewForbidden(
+				schema.GroupResource{Resource: "secrets"}, "converted", errors.New("no"))
+		})
+	require.Nil(t, reflectSource(map[string][]byte{"cert": []byte("cert"), "key": []byte("key")}, ""))
+	assert.Equal(t, 2.0, skipped())
+	assert.Equal(t, v1.SecretTypeTLS, get().Type)
+}
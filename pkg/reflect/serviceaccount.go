@@ -0,0 +1,179 @@
+package reflect
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// serviceAccountKind is a kind whose objects can be attached
+// to service accounts as image pull secrets
+type serviceAccountKind interface {
+	ServiceAccounts(namespace string) corev1.ServiceAccountInterface
+}
+
+// attachToServiceAccounts adds the reflected object to the image pull secrets
+// of the service accounts in the namespace, and marks the service accounts so
+// that it is only ever detached from the ones it was attached to. Service
+// accounts which don't exist are skipped, as are kinds which can't be attached
+// to service accounts. Service accounts which the reflector isn't allowed to
+// change are counted as failed attaches, without failing the reflection.
+func attachToServiceAccounts(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	name string,
+	namespace string,
+	serviceAccounts []string,
+) error {
+	attachable, ok := client.(serviceAccountKind)
+	if !ok || len(serviceAccounts) == 0 {
+		return nil
+	}
+
+	accounts := attachable.ServiceAccounts(namespace)
+	for _, saName := range serviceAccounts {
+		sa, err := accounts.Get(ctx, saName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			logger.Info().
+				Str("serviceAccount", saName).
+				Msg("service account does not exist, not attaching")
+			continue
+		} else if err == nil && hasPullSecret(sa, name) {
+			continue
+		} else if err == nil {
+			sa.ImagePullSecrets = append(sa.ImagePullSecrets, v1.LocalObjectReference{Name: name})
+			markAttached(sa, name)
+			_, err = accounts.Update(ctx, sa, metav1.UpdateOptions{})
+		}
+
+		labels := []string{"attach", client.Name(), name, "true", namespace}
+		if err != nil {
+			labels[3] = "false"
+		}
+		reflectorReflections.WithLabelValues(labels...).Inc()
+
+		if apierrors.IsForbidden(err) {
+			// the reflection itself succeeded, so only the attach failed
+			logger.Warn().
+				Err(err).
+				Str("serviceAccount", saName).
+				Msgf("not allowed to attach %s to service account", client.Name())
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "unable to attach %s to service account %s", client.Name(), saName)
+		}
+		logger.Info().
+			Str("serviceAccount", saName).
+			Msgf("attached %s to service account", client.Name())
+	}
+	return nil
+}
+
+// detachFromServiceAccounts removes the deleted reflection of an object from
+// the image pull secrets of the service accounts in the namespace which the
+// reflector attached it to. Image pull secrets which were added by anyone else
+// are left alone. A reflector which isn't allowed to list the service accounts
+// never attached to them.
+func detachFromServiceAccounts(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	name string,
+	namespace string,
+) error {
+	attachable, ok := client.(serviceAccountKind)
+	if !ok {
+		return nil
+	}
+
+	accounts := attachable.ServiceAccounts(namespace)
+	found, err := accounts.List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		logger.Debug().Msg("not allowed to list service accounts, not detaching")
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "unable to list service accounts")
+	}
+
+	for i := range found.Items {
+		sa := &found.Items[i]
+		if !isAttached(sa, name) {
+			continue
+		}
+
+		pullSecrets := []v1.LocalObjectReference{}
+		for _, ref := range sa.ImagePullSecrets {
+			if ref.Name != name {
+				pullSecrets = append(pullSecrets, ref)
+			}
+		}
+		sa.ImagePullSecrets = pullSecrets
+		unmarkAttached(sa, name)
+		if _, err := accounts.Update(ctx, sa, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "unable to detach %s from service account %s", client.Name(), sa.Name)
+		}
+		logger.Info().
+			Str("serviceAccount", sa.Name).
+			Msgf("detached %s from service account", client.Name())
+	}
+	return nil
+}
+
+// isAttached checks if the reflector attached the image pull secret to
+// the service account
+func isAttached(sa *v1.ServiceAccount, name string) bool {
+	for _, attached := range annotations.AttachedSecrets(sa.Annotations) {
+		if attached == name {
+			return true
+		}
+	}
+	return false
+}
+
+// markAttached records that the reflector attached the image pull secret
+// to the service account
+func markAttached(sa *v1.ServiceAccount, name string) {
+	if isAttached(sa, name) {
+		return
+	}
+	if sa.Annotations == nil {
+		sa.Annotations = map[string]string{}
+	}
+	attached := append(annotations.AttachedSecrets(sa.Annotations), name)
+	sa.Annotations[annotations.AttachedSecretsAnnotation] = strings.Join(attached, ",")
+}
+
+// unmarkAttached removes the image pull secret from the ones the reflector
+// attached to the service account
+func unmarkAttached(sa *v1.ServiceAccount, name string) {
+	attached := []string{}
+	for _, secret := range annotations.AttachedSecrets(sa.Annotations) {
+		if secret != name {
+			attached = append(attached, secret)
+		}
+	}
+	if len(attached) == 0 {
+		delete(sa.Annotations, annotations.AttachedSecretsAnnotation)
+		return
+	}
+	sa.Annotations[annotations.AttachedSecretsAnnotation] = strings.Join(attached, ",")
+}
+
+// hasPullSecret checks if the service account references the image pull secret
+func hasPullSecret(sa *v1.ServiceAccount, name string) bool {
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
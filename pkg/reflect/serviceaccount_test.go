@@ -0,0 +1,296 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func serviceAccount(name string, namespace string, pullSecrets ...string) *v1.ServiceAccount {
+	sa := &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	for _, secret := range pullSecrets {
+		sa.ImagePullSecrets = append(sa.ImagePullSecrets, v1.LocalObjectReference{Name: secret})
+	}
+	return sa
+}
+
+// attachedServiceAccount is a service account which the reflector attached
+// the pull secrets to
+func attachedServiceAccount(name string, namespace string, pullSecrets ...string) *v1.ServiceAccount {
+	sa := serviceAccount(name, namespace, pullSecrets...)
+	for _, secret := range pullSecrets {
+		markAttached(sa, secret)
+	}
+	return sa
+}
+
+func attachedSecrets(t *testing.T, client *fake.Clientset, name string, namespace string) []string {
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	require.Nil(t, err)
+	return annotations.AttachedSecrets(sa.Annotations)
+}
+
+func pullSecrets(t *testing.T, client *fake.Clientset, name string, namespace string) []string {
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	require.Nil(t, err)
+	names := []string{}
+	for _, ref := range sa.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+func TestAttachToServiceAccounts(t *testing.T) {
+	tests := []struct {
+		descrip         string
+		existing        []*v1.ServiceAccount
+		serviceAccounts []string
+		configMaps      bool
+		updateErr       error
+		expected        map[string][]string
+		attached        map[string][]string
+	}{
+		{
+			"attaches to every service account",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns"),
+				serviceAccount("builder", "ns", "other"),
+			},
+			[]string{"default", "builder"},
+			false,
+			nil,
+			map[string][]string{
+				"default": {"thing"},
+				"builder": {"other", "thing"},
+			},
+			map[string][]string{
+				"default": {"thing"},
+				"builder": {"thing"},
+			},
+		},
+		{
+			"does not attach or mark pull secrets added by hand",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns", "thing"),
+			},
+			[]string{"default"},
+			false,
+			nil,
+			map[string][]string{
+				"default": {"thing"},
+			},
+			map[string][]string{
+				"default": {},
+			},
+		},
+		{
+			"skips missing service accounts",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns"),
+			},
+			[]string{"missing", "default"},
+			false,
+			nil,
+			map[string][]string{
+				"default": {"thing"},
+			},
+			map[string][]string{
+				"default": {"thing"},
+			},
+		},
+		{
+			"does not attach config maps",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns"),
+			},
+			[]string{"default"},
+			true,
+			nil,
+			map[string][]string{
+				"default": {},
+			},
+			map[string][]string{
+				"default": {},
+			},
+		},
+		{
+			"fails to update the service account",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns"),
+			},
+			[]string{"default"},
+			false,
+			errors.New("some error"),
+			map[string][]string{
+				"default": {},
+			},
+			map[string][]string{
+				"default": {},
+			},
+		},
+		{
+			"is not allowed to update the service account",
+			[]*v1.ServiceAccount{
+				serviceAccount("default", "ns"),
+			},
+			[]string{"default"},
+			false,
+			apierrors.NewForbidden(v1.Resource("serviceaccounts"), "default", errors.New("some error")),
+			map[string][]string{
+				"default": {},
+			},
+			map[string][]string{
+				"default": {},
+			},
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			objs := []runtime.Object{}
+			for _, sa := range test.existing {
+				objs = append(objs, sa)
+			}
+			client := fake.NewSimpleClientset(objs...)
+			if test.updateErr != nil {
+				client.PrependReactor("update", "serviceaccounts",
+					func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+						return true, nil, test.updateErr
+					})
+			}
+			k := secretsClient(client)
+			if test.configMaps {
+				k = configMapsClient(client)
+			}
+
+			err := attachToServiceAccounts(
+				context.Background(),
+				zerolog.New(bytes.NewBuffer([]byte{})),
+				k,
+				"thing",
+				"ns",
+				test.serviceAccounts)
+			// forbidden attaches are counted, but don't fail the reflection
+			if test.updateErr != nil && !apierrors.IsForbidden(test.updateErr) {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			for name, expected := range test.expected {
+				assert.Equal(t, expected, pullSecrets(t, client, name, "ns"))
+				assert.Equal(t, test.attached[name], attachedSecrets(t, client, name, "ns"))
+			}
+		})
+	}
+}
+
+func TestDetachFromServiceAccounts(t *testing.T) {
+	builder := attachedServiceAccount("builder", "ns", "thing", "other")
+	builder.ImagePullSecrets = append(builder.ImagePullSecrets, v1.LocalObjectReference{Name: "manual"})
+	client := fake.NewSimpleClientset(
+		attachedServiceAccount("default", "ns", "thing"),
+		builder,
+		// added to the service account by hand, not by the reflector
+		serviceAccount("manual", "ns", "thing"),
+		attachedServiceAccount("default", "other-ns", "thing"),
+	)
+
+	require.Nil(t, detachFromServiceAccounts(
+		context.Background(),
+		zerolog.New(bytes.NewBuffer([]byte{})),
+		secretsClient(client),
+		"thing",
+		"ns"))
+
+	assert.Equal(t, []string{}, pullSecrets(t, client, "default", "ns"))
+	assert.Equal(t, []string{}, attachedSecrets(t, client, "default", "ns"))
+	assert.Equal(t, []string{"other", "manual"}, pullSecrets(t, client, "builder", "ns"))
+	assert.Equal(t, []string{"other"}, attachedSecrets(t, client, "builder", "ns"))
+	assert.Equal(t, []string{"thing"}, pullSecrets(t, client, "manual", "ns"))
+	assert.Equal(t, []string{"thing"}, pullSecrets(t, client, "default", "other-ns"))
+}
+
+func TestDetachFromServiceAccountsForbidden(t *testing.T) {
+	client := fake.NewSimpleClientset(attachedServiceAccount("default", "ns", "thing"))
+	client.PrependReactor("list", "serviceaccounts",
+		func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, apierrors.NewForbidden(
+				v1.Resource("serviceaccounts"), "", errors.New("some error"))
+		})
+
+	// reflectors which may not list service accounts never attached to them
+	require.Nil(t, detachFromServiceAccounts(
+		context.Background(),
+		zerolog.New(bytes.NewBuffer([]byte{})),
+		secretsClient(client),
+		"thing",
+		"ns"))
+}
+
+func TestReflectAttachesToServiceAccounts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset(
+		serviceAccount("default", "ns1"),
+		serviceAccount("default", "ns2"),
+	)
+	require.Nil(t, reflectToNamespaces(
+		ctx,
+		zerolog.Nop(),
+		secretsClient(client),
+		nil,
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "thing",
+				Namespace: "source",
+				Annotations: map[string]string{
+					annotations.ReflectAnnotation:                 "true",
+					annotations.AttachToServiceAccountsAnnotation: "default",
+				},
+			},
+			Type: v1.SecretTypeDockerConfigJson,
+		},
+		[]string{"ns1", "ns2"},
+		nil,
+		namespaceLister(t),
+		false,
+		false,
+		2))
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		assert.Equal(t, []string{"thing"}, pullSecrets(t, client, "default", ns))
+		sec, err := client.CoreV1().Secrets(ns).Get(ctx, "thing", metav1.GetOptions{})
+		require.Nil(t, err)
+		assert.NotContains(t, sec.Annotations, annotations.AttachToServiceAccountsAnnotation)
+	}
+
+	wg := &sync.WaitGroup{}
+	errChan := make(chan error, 1)
+	deleteObject(ctx, zerolog.Nop(), wg, secretsClient(client), []string{"thing"}, "ns1", errChan)
+	wg.Wait()
+	assert.Len(t, errChan, 0)
+	assert.Equal(t, []string{}, pullSecrets(t, client, "default", "ns1"))
+	assert.Equal(t, []string{"thing"}, pullSecrets(t, client, "default", "ns2"))
+}
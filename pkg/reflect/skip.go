@@ -0,0 +1,64 @@
+package reflect
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// ErrorNamespaceTerminating is used when a namespace is skipped because
+// it is being deleted
+var ErrorNamespaceTerminating = errors.New("namespace is terminating")
+
+// isSkippable checks if an error reflecting to a namespace will not
+// go away on a retry, i.e. the reflector isn't allowed to write to the
+// namespace, the namespace is being deleted, the reflection is immutable,
+// the secret lacks the keys of the type it is converted to, or another
+// object already exists under the name of the reflection
+func isSkippable(err error) bool {
+	return apierrors.IsForbidden(err) ||
+		apierrors.HasStatusCause(err, v1.NamespaceTerminatingCause) ||
+		errors.Is(err, ErrorImmutable) ||
+		errors.Is(err, ErrorMissingTypeKeys) ||
+		errors.Is(err, ErrorCollision)
+}
+
+// skipNamespace logs and counts a namespace that the object could
+// not be reflected to, without failing the reflection to the other
+// namespaces
+func skipNamespace(
+	logger zerolog.Logger,
+	client kind,
+	obj object,
+	ns string,
+	err error,
+) {
+	logger.Warn().
+		Err(err).
+		Str("reflectionNamespace", ns).
+		Msg("unable to reflect to namespace, skipping")
+	reflectorReflections.WithLabelValues("skip", client.Name(), obj.GetName(), "false", ns).Inc()
+}
+
+// filterTerminating removes the namespaces which are being deleted, as
+// objects can't be created in them
+func filterTerminating(
+	logger zerolog.Logger,
+	nsLister corelisters.NamespaceLister,
+	client kind,
+	obj object,
+	namespaces []string,
+) []string {
+	active := []string{}
+	for _, ns := range namespaces {
+		found, err := nsLister.Get(ns)
+		if err == nil && found.Status.Phase == v1.NamespaceTerminating {
+			skipNamespace(logger, client, obj, ns, ErrorNamespaceTerminating)
+			continue
+		}
+		active = append(active, ns)
+	}
+	return active
+}
@@ -0,0 +1,189 @@
+package reflect
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func terminatingError(ns string) error {
+	err := apierrors.NewForbidden(
+		schema.GroupResource{Resource: "secrets"}, "thing",
+		errors.Errorf("namespace %s is being terminated", ns))
+	err.ErrStatus.Details.Causes = []metav1.StatusCause{
+		{Type: v1.NamespaceTerminatingCause},
+	}
+	return err
+}
+
+func TestIsSkippable(t *testing.T) {
+	tests := []struct {
+		descrip   string
+		err       error
+		skippable bool
+	}{
+		{
+			"no error is not skippable",
+			nil,
+			false,
+		},
+		{
+			"a generic error is not skippable",
+			errors.New("some error"),
+			false,
+		},
+		{
+			"a not found error is not skippable",
+			apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "thing"),
+			false,
+		},
+		{
+			"a collision is skippable",
+			errors.Wrap(ErrorCollision, "secret target/thing is a reflection of other/thing"),
+			true,
+		},
+		{
+			"missing keys of the target type are skippable",
+			errors.Wrap(ErrorMissingTypeKeys, "kubernetes.io/tls secrets require the key \"tls.key\""),
+			true,
+		},
+		{
+			"a forbidden error is skippable",
+			apierrors.NewForbidden(
+				schema.GroupResource{Resource: "secrets"}, "thing", errors.New("no")),
+			true,
+		},
+		{
+			"a terminating namespace error is skippable",
+			terminatingError("deleted"),
+			true,
+		},
+		{
+			"a wrapped forbidden error is skippable",
+			errors.Wrap(terminatingError("deleted"), "error while creating secret"),
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.skippable, isSkippable(test.err))
+		})
+	}
+}
+
+func TestFilterTerminating(t *testing.T) {
+	t.Parallel()
+	terminating := &v1.Namespace{}
+	terminating.Name = "deleted"
+	terminating.Status.Phase = v1.NamespaceTerminating
+	active := &v1.Namespace{}
+	active.Name = "active"
+	active.Status.Phase = v1.NamespaceActive
+
+	sec := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "filter-terminating",
+			Namespace: "source",
+		},
+	}
+	namespaces := filterTerminating(
+		zerolog.New(bytes.NewBuffer([]byte{})),
+		namespaceLister(t, terminating, active),
+		secretsClient(fake.NewSimpleClientset()),
+		sec,
+		[]string{"active", "deleted", "not-cached"})
+	assert.Equal(t, []string{"active", "not-cached"}, namespaces)
+
+	m, err := reflectorReflections.GetMetricWithLabelValues(
+		"skip", "secret", sec.Name, "false", "deleted")
+	require.Nil(t, err)
+	metric := &dto.Metric{}
+	require.Nil(t, m.Write(metric))
+	assert.Equal(t, float64(1), metric.Counter.GetValue())
+}
+
+func TestReflectSecretSkips(t *testing.T) {
+	tests := []struct {
+		descrip string
+		err     error
+		skipped bool
+	}{
+		{
+			"skips a forbidden namespace",
+			apierrors.NewForbidden(
+				schema.GroupResource{Resource: "secrets"}, "thing", errors.New("no")),
+			true,
+		},
+		{
+			"skips a terminating namespace",
+			terminatingError("blergh"),
+			true,
+		},
+		{
+			"fails on other errors",
+			errors.New("some error"),
+			false,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "*",
+				func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+					return true, nil, test.err
+				})
+
+			wg := &sync.WaitGroup{}
+			errChan := make(chan error, 2)
+			reflectObject(
+				ctx,
+				zerolog.Nop(),
+				wg,
+				secretsClient(client),
+				nil,
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "this",
+						Namespace:   "thing",
+						Annotations: map[string]string{},
+					},
+				},
+				"this",
+				"hash",
+				[]string{},
+				nil,
+				false,
+				"blergh",
+				errChan)
+			wg.Wait()
+
+			select {
+			case err := <-errChan:
+				assert.False(t, test.skipped, "unexpected error: %v", err)
+			default:
+				assert.True(t, test.skipped, "expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package reflect
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/havulv/reflector/pkg/queue"
+)
+
+// objectStores are the caches of the watched objects, keyed by the
+// namespace each cache watches. A cache keyed by the empty namespace
+// watches every namespace.
+type objectStores map[string]cache.Indexer
+
+// getByKey fetches an object from the cache watching its namespace
+func (s objectStores) getByKey(key string) (interface{}, bool, error) {
+	namespace, _ := queue.ParseWorkQueueKey(key)
+	store, ok := s[namespace]
+	if !ok {
+		store, ok = s[v1.NamespaceAll]
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return store.GetByKey(key)
+}
+
+// list lists the objects in every cache
+func (s objectStores) list() []interface{} {
+	objs := []interface{}{}
+	for _, store := range s {
+		objs = append(objs, store.List()...)
+	}
+	return objs
+}
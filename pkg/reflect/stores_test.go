@@ -0,0 +1,94 @@
+package reflect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func storeOf(t *testing.T, secrets ...*v1.Secret) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, sec := range secrets {
+		require.Nil(t, indexer.Add(sec))
+	}
+	return indexer
+}
+
+func secretIn(namespace string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "thing",
+			Namespace: namespace,
+		},
+	}
+}
+
+func TestSecretStoresGetByKey(t *testing.T) {
+	tests := []struct {
+		descrip string
+		stores  objectStores
+		key     string
+		exists  bool
+	}{
+		{
+			"finds a secret in the store for its namespace",
+			objectStores{
+				"hub-a": storeOf(t, secretIn("hub-a")),
+				"hub-b": storeOf(t, secretIn("hub-b")),
+			},
+			"hub-b/thing",
+			true,
+		},
+		{
+			"finds a secret in the store for every namespace",
+			objectStores{
+				v1.NamespaceAll: storeOf(t, secretIn("hub-a")),
+			},
+			"hub-a/thing",
+			true,
+		},
+		{
+			"does not find a secret in an unwatched namespace",
+			objectStores{
+				"hub-a": storeOf(t, secretIn("hub-a")),
+			},
+			"other/thing",
+			false,
+		},
+		{
+			"does not find a deleted secret",
+			objectStores{
+				"hub-a": storeOf(t),
+			},
+			"hub-a/thing",
+			false,
+		},
+	}
+
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			obj, exists, err := test.stores.getByKey(test.key)
+			assert.Nil(t, err)
+			assert.Equal(t, test.exists, exists)
+			if test.exists {
+				assert.NotNil(t, obj)
+			}
+		})
+	}
+}
+
+func TestSecretStoresList(t *testing.T) {
+	t.Parallel()
+	stores := objectStores{
+		"hub-a": storeOf(t, secretIn("hub-a")),
+		"hub-b": storeOf(t, secretIn("hub-b")),
+		"hub-c": storeOf(t),
+	}
+	assert.Len(t, stores.list(), 2)
+}
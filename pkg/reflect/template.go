@@ -0,0 +1,145 @@
+package reflect
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// templateFuncs are the functions available to templates. They can't
+// reach anything outside of the data they are given.
+var templateFuncs = template.FuncMap{
+	"b64enc": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+	"b64dec": func(value string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		return string(decoded), err
+	},
+	"json": func(value interface{}) (string, error) {
+		raw, err := json.Marshal(value)
+		return string(raw), err
+	},
+	"bcrypt": bcryptHash,
+	"htpasswd": func(user string, password string) (string, error) {
+		hashed, err := bcryptHash(password)
+		return user + ":" + hashed, err
+	},
+}
+
+// bcryptHash hashes the password with bcrypt and a random salt
+func bcryptHash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hashed), err
+}
+
+// templateValues are the data of the object which templates are rendered
+// from, by their key. Objects other than secrets and config maps have none.
+func templateValues(obj object) map[string]string {
+	values := map[string]string{}
+	switch o := obj.(type) {
+	case *v1.Secret:
+		for key, value := range o.Data {
+			values[key] = string(value)
+		}
+		for key, value := range o.StringData {
+			values[key] = value
+		}
+	case *v1.ConfigMap:
+		for key, value := range o.BinaryData {
+			values[key] = string(value)
+		}
+		for key, value := range o.Data {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// renderTemplates renders the templates from the values into the data of
+// the object, replacing any data that the object already has under a key.
+// Objects other than secrets and config maps are left as they are.
+func renderTemplates(obj object, templates map[string]string, values map[string]string) error {
+	switch obj.(type) {
+	case *v1.Secret, *v1.ConfigMap:
+	default:
+		return nil
+	}
+
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		tmpl, err := template.New(key).
+			Option("missingkey=error").
+			Funcs(templateFuncs).
+			Parse(templates[key])
+		if err != nil {
+			return errors.Wrapf(annotations.ErrorInvalidTemplate, "unable to parse template of %q: %s", key, err)
+		}
+		rendered := &bytes.Buffer{}
+		if err := tmpl.Execute(rendered, values); err != nil {
+			return errors.Wrapf(err, "unable to render template of %q", key)
+		}
+
+		setValue(obj, key, rendered.Bytes())
+	}
+	return nil
+}
+
+// setValue sets the data of a secret or config map under the key, replacing
+// any data that it already has under the key. Config map values which are
+// not valid UTF-8 are kept as binary data.
+func setValue(obj object, key string, value []byte) {
+	switch o := obj.(type) {
+	case *v1.Secret:
+		if o.Data == nil {
+			o.Data = map[string][]byte{}
+		}
+		delete(o.StringData, key)
+		o.Data[key] = value
+	case *v1.ConfigMap:
+		delete(o.Data, key)
+		delete(o.BinaryData, key)
+		if !utf8.Valid(value) {
+			if o.BinaryData == nil {
+				o.BinaryData = map[string][]byte{}
+			}
+			o.BinaryData[key] = value
+			return
+		}
+		if o.Data == nil {
+			o.Data = map[string]string{}
+		}
+		o.Data[key] = string(value)
+	}
+}
+
+// hashTemplates adds the templates, and the values they are rendered from,
+// to the hash of an object. Rendered data can't be hashed instead, as it
+// differs on every render when it is salted, e.g. by bcrypt.
+func hashTemplates(hash string, templates map[string]string, values map[string]string) (string, error) {
+	raw, err := json.Marshal(struct {
+		Hash      string
+		Templates map[string]string
+		Values    map[string]string
+	}{hash, templates, values})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal templates")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw)), nil
+}
@@ -0,0 +1,163 @@
+package reflect
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+// UnreflectPolicy determines what happens to the reflections of an
+// object when the object stops being reflected
+type UnreflectPolicy string
+
+const (
+	// UnreflectFreeze leaves the reflections untouched and owned by the
+	// reflector, so they are updated again if reflection is re-enabled
+	UnreflectFreeze UnreflectPolicy = "freeze"
+	// UnreflectOrphan releases the reflections from the reflector's
+	// ownership, so they are never updated or deleted by it again
+	UnreflectOrphan UnreflectPolicy = "orphan"
+	// UnreflectDelete deletes the reflections
+	UnreflectDelete UnreflectPolicy = "delete"
+)
+
+// ErrorInvalidUnreflectPolicy is used when an unknown unreflect policy is given
+var ErrorInvalidUnreflectPolicy = errors.New("invalid unreflect policy")
+
+// parseUnreflectPolicy validates the policy, defaulting to freezing reflections
+func parseUnreflectPolicy(policy UnreflectPolicy) (UnreflectPolicy, error) {
+	switch policy {
+	case "":
+		return UnreflectFreeze, nil
+	case UnreflectFreeze, UnreflectOrphan, UnreflectDelete:
+		return policy, nil
+	default:
+		return "", errors.Wrapf(ErrorInvalidUnreflectPolicy, "%q", policy)
+	}
+}
+
+// unreflect applies the unreflect policy to the existing reflections of
+// an object which is no longer reflected. The reflections are listed by
+// their label, so they are found even if another reflector, or the same
+// one before it restarted, reflected them.
+func unreflect(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	policy UnreflectPolicy,
+	name string,
+	sourceNamespace string,
+	concurrency int,
+) error {
+	existing, err := findReflections(ctx, client, name, sourceNamespace)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find namespaces %s was reflected to", client.Name())
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+
+	logger.Info().
+		Str("policy", string(policy)).
+		Strs("namespaces", existing.namespaces()).
+		Msg("object is no longer reflected, applying unreflect policy to reflections")
+	switch policy {
+	case UnreflectDelete:
+		return cascadeDelete(ctx, logger, client, existing, concurrency)
+	case UnreflectOrphan:
+		return batchOverNamespaces(
+			concurrency,
+			existing.namespaces(),
+			orphanLambda(ctx, logger, client, existing))
+	default:
+		// frozen reflections are left as they are, but are still counted
+		for ns, names := range existing {
+			for _, reflection := range names {
+				reflectorReflections.WithLabelValues(
+					string(UnreflectFreeze), client.Name(), reflection, "true", ns).Inc()
+			}
+		}
+		return nil
+	}
+}
+
+func orphanLambda(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client kind,
+	existing reflections,
+) func(wg *sync.WaitGroup, ns string, errChan chan error) {
+	return func(wg *sync.WaitGroup, ns string, errChan chan error) {
+		orphanObject(
+			ctx, logger.With().
+				Str("reflectionNamespace", ns).Logger(),
+			wg, client, existing[ns], ns, errChan)
+	}
+}
+
+func orphanObject(
+	ctx context.Context,
+	logger zerolog.Logger,
+	wg *sync.WaitGroup,
+	client kind,
+	names []string,
+	ns string,
+	errChan chan error,
+) {
+	// spin off a goroutine for every level of concurrency
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, name := range names {
+			if err := orphan(ctx, client, name, ns); err != nil {
+				logger.Error().Err(err).Msgf("unable to orphan %s", client.Name())
+				errChan <- errors.Wrapf(
+					err,
+					"error while orphaning %s in the namespace", client.Name())
+				return
+			}
+		}
+	}()
+}
+
+// orphan removes the reflector's ownership from a reflected object
+func orphan(
+	ctx context.Context,
+	client kind,
+	name string,
+	ns string,
+) (err error) {
+	labels := []string{"orphan", client.Name(), name, "true", ns}
+	defer func() {
+		if err != nil {
+			labels[3] = "false"
+		}
+		reflectorReflections.WithLabelValues(labels...).Inc()
+	}()
+
+	objects := client.Objects(ns)
+	reflected, err := objects.Get(ctx, name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "error while getting reflected %s", client.Name())
+	}
+
+	objAnnotations := reflected.GetAnnotations()
+	delete(objAnnotations, annotations.ReflectionOwnerAnnotation)
+	delete(objAnnotations, annotations.ReflectionHashAnnotation)
+	reflected.SetAnnotations(objAnnotations)
+	// orphans are no longer listed as reflections of the object
+	objLabels := reflected.GetLabels()
+	delete(objLabels, annotations.ReflectionOfLabel)
+	reflected.SetLabels(objLabels)
+	if err = objects.Update(ctx, reflected); err != nil {
+		return errors.Wrapf(err, "error while updating %s", client.Name())
+	}
+	return nil
+}
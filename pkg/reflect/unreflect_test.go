@@ -0,0 +1,219 @@
+package reflect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/havulv/reflector/pkg/annotations"
+)
+
+func TestParseUnreflectPolicy(t *testing.T) {
+	tests := []struct {
+		descrip string
+		in      UnreflectPolicy
+		out     UnreflectPolicy
+		err     bool
+	}{
+		{"defaults to freeze", "", UnreflectFreeze, false},
+		{"accepts freeze", "freeze", UnreflectFreeze, false},
+		{"accepts orphan", "orphan", UnreflectOrphan, false},
+		{"accepts delete", "delete", UnreflectDelete, false},
+		{"rejects unknown policies", "keep", "", true},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			policy, err := parseUnreflectPolicy(test.in)
+			if test.err {
+				assert.True(t, errors.Is(err, ErrorInvalidUnreflectPolicy))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.out, policy)
+		})
+	}
+}
+
+func TestUnreflect(t *testing.T) {
+	tests := []struct {
+		descrip string
+		policy  UnreflectPolicy
+		listErr error
+		deleted bool
+		owned   bool
+	}{
+		{
+			"freezing leaves reflections untouched",
+			UnreflectFreeze,
+			nil,
+			false,
+			true,
+		},
+		{
+			"orphaning releases ownership of reflections",
+			UnreflectOrphan,
+			nil,
+			false,
+			false,
+		},
+		{
+			"deleting removes the reflections",
+			UnreflectDelete,
+			nil,
+			true,
+			false,
+		},
+		{
+			"fails when reflections can't be found",
+			UnreflectDelete,
+			errors.New("some error"),
+			false,
+			true,
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client := fake.NewSimpleClientset(
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "unreflected",
+						Namespace: "reflected",
+						Labels:    reflectionOf("source", "unreflected"),
+						Annotations: map[string]string{
+							annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+							annotations.ReflectionHashAnnotation:  "some-hash",
+							annotations.ReflectedFromAnnotation:   "source",
+						},
+					},
+				})
+			if test.listErr != nil {
+				client.PrependReactor("list", "*",
+					func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+						return true, nil, test.listErr
+					})
+			}
+
+			buf := &lockedBuffer{}
+			err := unreflect(
+				ctx,
+				zerolog.New(buf),
+				secretsClient(client),
+				test.policy,
+				"unreflected",
+				"source",
+				1)
+			if test.listErr != nil {
+				assert.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			sec, err := client.CoreV1().Secrets("reflected").Get(ctx, "unreflected", metav1.GetOptions{})
+			if test.deleted {
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, test.owned, annotations.CanOperate(sec.Annotations))
+			// orphans are no longer listed as reflections
+			_, labelled := sec.Labels[annotations.ReflectionOfLabel]
+			assert.Equal(t, test.owned, labelled)
+			// every policy is logged, including freezing the reflections
+			assert.Contains(t, buf.String(), string(test.policy))
+		})
+	}
+}
+
+func TestOrphan(t *testing.T) {
+	tests := []struct {
+		descrip string
+		exists  bool
+		err     error
+	}{
+		{
+			"orphans an existing secret",
+			true,
+			nil,
+		},
+		{
+			"ignores a missing secret",
+			false,
+			nil,
+		},
+		{
+			"fails to update the secret",
+			true,
+			errors.New("some error"),
+		},
+	}
+	for _, l := range tests {
+		test := l
+		t.Run(test.descrip, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ns := "orphan-" + test.descrip
+			client := fake.NewSimpleClientset()
+			if test.exists {
+				client = fake.NewSimpleClientset(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "orphaned",
+						Namespace: ns,
+						Annotations: map[string]string{
+							annotations.ReflectionOwnerAnnotation: annotations.ReflectionOwned,
+							annotations.ReflectionHashAnnotation:  "some-hash",
+						},
+					},
+				})
+			}
+			if test.err != nil {
+				client.PrependReactor("update", "*",
+					func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+						return true, nil, test.err
+					})
+			}
+
+			err := orphan(ctx, secretsClient(client), "orphaned", ns)
+			success := "true"
+			if test.err != nil {
+				assert.NotNil(t, err)
+				success = "false"
+			} else {
+				assert.Nil(t, err)
+			}
+
+			m, err := reflectorReflections.GetMetricWithLabelValues("orphan", "secret", "orphaned", success, ns)
+			require.Nil(t, err)
+			metric := &dto.Metric{}
+			require.Nil(t, m.Write(metric))
+			assert.Equal(t, float64(1), metric.Counter.GetValue())
+
+			if test.exists && test.err == nil {
+				sec, err := client.CoreV1().Secrets(ns).Get(ctx, "orphaned", metav1.GetOptions{})
+				require.Nil(t, err)
+				assert.NotContains(t, sec.Annotations, annotations.ReflectionOwnerAnnotation)
+				assert.NotContains(t, sec.Annotations, annotations.ReflectionHashAnnotation)
+			}
+		})
+	}
+}
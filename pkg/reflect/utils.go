@@ -47,3 +47,29 @@ func waitUntilError(
 	}
 	return nil
 }
+
+// membership tracks the group which each object, by its key, is a member
+// of, such as an aggregate, so that the group can be updated when one of
+// its members leaves it or is deleted
+type membership struct {
+	mu      sync.Mutex
+	members map[string]string
+}
+
+// swap records the group of the object, which is left if the group is
+// empty, and returns the group the object was a member of before
+func (m *membership) swap(key string, group string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.members == nil {
+		m.members = map[string]string{}
+	}
+	previous := m.members[key]
+	if group == "" {
+		delete(m.members, key)
+	} else {
+		m.members[key] = group
+	}
+	return previous
+}
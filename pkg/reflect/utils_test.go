@@ -1,6 +1,7 @@
 package reflect
 
 import (
+	"bytes"
 	"errors"
 	"sync"
 	"testing"
@@ -8,6 +9,25 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// lockedBuffer is a log buffer which the goroutines of a reflector can
+// write to while the test reads it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
 func TestWaitUntilError(t *testing.T) {
 	tests := []struct {
 		d   string